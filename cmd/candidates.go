@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// candidateTemperature spreads the temperatures of n requests between 0.3
+// and 1 so that the candidates differ. A single request keeps the
+// generator default.
+func candidateTemperature(i, n int) float64 {
+	if n <= 1 {
+		return 0
+	}
+	return 0.3 + 0.7*float64(i)/float64(n-1)
+}
+
+// message asks for the commit message, picked among n candidates when n
+// is over one.
+func (f commitFlow) message(ctx context.Context, req genRequest, diff string, n int) (string, error) {
+	if n <= 1 {
+		return f.regenerate(ctx, req, diff)
+	}
+	msgs, err := f.candidates(ctx, req, diff, n)
+	if err != nil {
+		return "", err
+	}
+	return f.pick(msgs)
+}
+
+// candidates asks for n messages at once, each at its own temperature, and
+// returns the distinct ones in request order. Failed requests are warned
+// about as long as one succeeds. The backends queue what they cannot serve
+// in parallel.
+func (f commitFlow) candidates(ctx context.Context, req genRequest, diff string, n int) ([]string, error) {
+	f.stream = false // the answers would interleave
+	fmt.Fprintf(f.out, "📝 generating %d candidates\n", n)
+	msgs := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := req
+			r.temperature = candidateTemperature(i, n)
+			msgs[i], errs[i] = f.regenerate(ctx, r, diff)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var ok []string
+	for i, err := range errs {
+		if err != nil {
+			warn("candidate %d: %v", i+1, err)
+			continue
+		}
+		ok = append(ok, msgs[i])
+	}
+	if len(ok) == 0 {
+		return nil, fmt.Errorf("no candidate: %w", errors.Join(errs...))
+	}
+	return dedupeCandidates(ok), nil
+}
+
+// dedupeCandidates drops the messages differing from a previous one only
+// by case or spacing.
+func dedupeCandidates(msgs []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, msg := range msgs {
+		key := strings.Join(strings.Fields(strings.ToLower(msg)), " ")
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, msg)
+	}
+	return out
+}
+
+// pick lets the user choose one of the candidates, with sk when it is
+// installed or from a numbered list otherwise. Without a terminal the
+// first one is taken.
+func (f commitFlow) pick(msgs []string) (string, error) {
+	if len(msgs) == 1 {
+		return msgs[0], nil
+	}
+	if !f.interactive {
+		warn("%d candidates but no terminal to pick one, taking the first", len(msgs))
+		return msgs[0], nil
+	}
+	if f.useSkim {
+		return f.skimCandidates(msgs)
+	}
+	for i, msg := range msgs {
+		fmt.Fprintf(f.out, "\n── candidate %d/%d ──\n%s\n", i+1, len(msgs), msg)
+	}
+	for {
+		answer, err := f.readLine(fmt.Sprintf("\npick a candidate [1-%d, q to quit] (1): ", len(msgs)))
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			return msgs[0], nil
+		}
+		if strings.HasPrefix(strings.ToLower(answer), "q") {
+			return "", f.keepCandidates(msgs)
+		}
+		if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(msgs) {
+			return msgs[i-1], nil
+		}
+	}
+}
+
+// skimCandidates lists the subjects in sk with the whole message in the
+// preview window.
+func (f commitFlow) skimCandidates(msgs []string) (string, error) {
+	dir, err := os.MkdirTemp("", "yag-candidates")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	var lines strings.Builder
+	byPath := make(map[string]string)
+	for i, msg := range msgs {
+		name := filepath.Join(dir, strconv.Itoa(i+1))
+		if err = os.WriteFile(name, []byte(msg+"\n"), 0644); err != nil {
+			return "", err
+		}
+		byPath[name] = msg
+		fmt.Fprintf(&lines, "%s\t%s\n", name, draft{msg: msg}.subject())
+	}
+	line, err := skim(strings.NewReader(lines.String()),
+		"--delimiter", "\t", "--with-nth", "2..", "--preview", "cat {1}", "--prompt", "candidate> ")
+	if errors.Is(err, errSkimNoMatch) || errors.Is(err, errSkimInterrupted) {
+		return "", f.keepCandidates(msgs)
+	}
+	if err != nil {
+		return "", err
+	}
+	name, _, _ := strings.Cut(line, "\t")
+	msg, ok := byPath[name]
+	if !ok {
+		return "", fmt.Errorf("sk picked an unknown candidate %q", line)
+	}
+	return msg, nil
+}
+
+// keepCandidates stashes every candidate when none is picked.
+func (f commitFlow) keepCandidates(msgs []string) error {
+	for _, msg := range msgs {
+		if _, err := f.stash(msg); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("no candidate picked, they are kept in %s", f.drafts.dir)
+}
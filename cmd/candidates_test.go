@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func Test_dedupeCandidates(t *testing.T) {
+	got := dedupeCandidates([]string{"add repo", "Add  repo", "", "fix tag\n\nbody", "fix tag body"})
+	want := []string{"add repo", "fix tag\n\nbody"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupeCandidates() = %q, want %q", got, want)
+	}
+}
+
+func Test_commitFlow_candidates(t *testing.T) {
+	gen := &answersGenerator{answers: []string{"add repo", "add  repo", "fix tag"}}
+	flow := commitFlow{repo: newFakeRepo(), gen: gen, out: &strings.Builder{}, stream: true, debug: zap.NewNop()}
+	got, err := flow.candidates(context.Background(), genRequest{prompt: "diff", maxTokens: 256}, "diff", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("candidates = %q, want 2 distinct", got)
+	}
+	var temperatures []float64
+	for _, req := range gen.reqs {
+		if req.stream != nil {
+			t.Error("candidates are streamed")
+		}
+		temperatures = append(temperatures, math.Round(req.temperature*100)/100)
+	}
+	sort.Float64s(temperatures)
+	if want := []float64{0.3, 0.65, 1}; !reflect.DeepEqual(temperatures, want) {
+		t.Errorf("temperatures = %v, want %v", temperatures, want)
+	}
+}
+
+func Test_commitFlow_pick(t *testing.T) {
+	msgs := []string{"add repo", "fix tag"}
+	tests := []struct {
+		name        string
+		answers     string
+		interactive bool
+		want        string
+		wantErr     bool
+	}{
+		{"no terminal", "", false, "add repo", false},
+		{"default", "\n", true, "add repo", false},
+		{"number", "3\nx\n2\n", true, "fix tag", false},
+		{"quit", "q\n", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			repo.root = t.TempDir()
+			drafts, err := openDrafts(repo, draftsConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			flow := commitFlow{
+				repo:        repo,
+				in:          bufio.NewReader(strings.NewReader(tt.answers)),
+				out:         &strings.Builder{},
+				drafts:      drafts,
+				interactive: tt.interactive,
+				debug:       zap.NewNop(),
+			}
+			got, err := flow.pick(msgs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pick() = %q, want %q", got, tt.want)
+			}
+			if kept, _ := drafts.list(); tt.wantErr && len(kept) != len(msgs) {
+				t.Errorf("%d drafts kept, want %d", len(kept), len(msgs))
+			}
+		})
+	}
+}
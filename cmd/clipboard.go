@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// clipboardConfig is the "clipboard" section of the configuration.
+type clipboardConfig struct {
+	// Method is auto, pbcopy, wl-copy, xclip, xsel, osc52 or file.
+	Method string `yaml:"method"`
+	// File receives the text with the file method, and when auto finds no
+	// clipboard. Defaults to yag/clipboard.txt in the user cache directory.
+	File string `yaml:"file"`
+}
+
+var clipboardMethods = []string{"auto", "pbcopy", "wl-copy", "xclip", "xsel", "osc52", "file"}
+
+// clipboard copies text somewhere the user can paste it from.
+type clipboard interface {
+	copy(s string) error
+	// String tells the user where the text went.
+	String() string
+}
+
+// cmdClipboard pipes the text to a clipboard tool.
+type cmdClipboard struct {
+	name string
+	args []string
+}
+
+func (c cmdClipboard) copy(s string) error {
+	x := exec.Command(c.name, c.args...)
+	x.Stdin = strings.NewReader(s)
+	x.Stderr = os.Stderr
+	if err := x.Run(); err != nil {
+		return fmt.Errorf("%s: %w", c.name, err)
+	}
+	return nil
+}
+
+func (c cmdClipboard) String() string {
+	return c.name
+}
+
+// errNoTerminal is osc52 without a controlling terminal, auto then tries
+// the next method without a warning.
+var errNoTerminal = errors.New("no terminal")
+
+// osc52Clipboard asks the terminal to set the clipboard, which works over
+// ssh. Inside tmux the sequence is passed through to the outer terminal.
+type osc52Clipboard struct {
+	tty  func() (io.WriteCloser, error) // opened by copy, closed once written
+	tmux bool
+}
+
+func (c osc52Clipboard) copy(s string) error {
+	w, err := c.tty()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errNoTerminal, err)
+	}
+	seq := "\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(s)) + "\a"
+	if c.tmux {
+		seq = "\033Ptmux;\033" + seq + "\033\\"
+	}
+	_, err = io.WriteString(w, seq)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c osc52Clipboard) String() string {
+	return "osc52 terminal escape sequence"
+}
+
+type fileClipboard struct {
+	name string
+}
+
+func (c fileClipboard) copy(s string) error {
+	if err := os.MkdirAll(filepath.Dir(c.name), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.name, []byte(s), 0644)
+}
+
+func (c fileClipboard) String() string {
+	return "file " + c.name
+}
+
+// clipboardEnv is what the clipboard detection looks at, faked in tests.
+type clipboardEnv struct {
+	goos     string
+	getenv   func(string) string
+	lookPath func(string) (string, error)
+	tty      func() (io.WriteCloser, error) // the controlling terminal
+}
+
+var systemClipboardEnv = clipboardEnv{
+	goos:     runtime.GOOS,
+	getenv:   os.Getenv,
+	lookPath: exec.LookPath,
+	tty: func() (io.WriteCloser, error) {
+		return os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	},
+}
+
+// clipboards lists the methods to try in order of preference.
+func (e clipboardEnv) clipboards(cfg clipboardConfig) ([]clipboard, error) {
+	method := cfg.Method
+	if method == "" {
+		method = "auto"
+	}
+	if !slices.Contains(clipboardMethods, method) {
+		return nil, fmt.Errorf("unknown clipboard method %q, want one of %s", method, strings.Join(clipboardMethods, ", "))
+	}
+	file := cfg.File
+	if file == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			dir = os.TempDir()
+		}
+		file = filepath.Join(dir, "yag", "clipboard.txt")
+	}
+	tools := map[string]clipboard{
+		"pbcopy":  cmdClipboard{name: "pbcopy"},
+		"wl-copy": cmdClipboard{name: "wl-copy"},
+		"xclip":   cmdClipboard{name: "xclip", args: []string{"-selection", "clipboard"}},
+		"xsel":    cmdClipboard{name: "xsel", args: []string{"--clipboard", "--input"}},
+	}
+	osc52 := osc52Clipboard{tty: e.tty, tmux: e.getenv("TMUX") != ""}
+	switch method {
+	case "file":
+		return []clipboard{fileClipboard{file}}, nil
+	case "osc52":
+		return []clipboard{osc52}, nil
+	case "auto":
+	default:
+		return []clipboard{tools[method]}, nil
+	}
+
+	var found []clipboard
+	remote := e.getenv("SSH_TTY") != "" || e.getenv("SSH_CONNECTION") != ""
+	if remote {
+		// a clipboard on the remote host is of no use
+		found = append(found, osc52)
+	}
+	var candidates []string
+	switch {
+	case e.goos == "darwin":
+		candidates = []string{"pbcopy"}
+	case e.getenv("WAYLAND_DISPLAY") != "":
+		candidates = []string{"wl-copy", "xclip", "xsel"}
+	case e.getenv("DISPLAY") != "":
+		candidates = []string{"xclip", "xsel"}
+	}
+	for _, name := range candidates {
+		if _, err := e.lookPath(name); err == nil {
+			found = append(found, tools[name])
+		}
+	}
+	if !remote {
+		found = append(found, osc52)
+	}
+	return append(found, fileClipboard{file}), nil
+}
+
+// copyToClipboard copies s with the configured method, or the first one
+// working, and tells which one was used.
+func copyToClipboard(cfg clipboardConfig, s string, out io.Writer) error {
+	return systemClipboardEnv.copy(cfg, s, out)
+}
+
+func (e clipboardEnv) copy(cfg clipboardConfig, s string, out io.Writer) error {
+	clipboards, err := e.clipboards(cfg)
+	if err != nil {
+		return err
+	}
+	for i, c := range clipboards {
+		err = c.copy(s)
+		if err == nil {
+			fmt.Fprintf(out, "📋 copied with %s\n", c)
+			return nil
+		}
+		if i < len(clipboards)-1 && !errors.Is(err, errNoTerminal) {
+			warn("clipboard %s failed: %v", c, err)
+		}
+	}
+	return fmt.Errorf("copy to clipboard: %w", err)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTTY is a terminal counting its opens and closes.
+type fakeTTY struct {
+	bytes.Buffer
+	opened, closed int
+}
+
+func (t *fakeTTY) Close() error {
+	t.closed++
+	return nil
+}
+
+// fakeClipboardEnv finds the tools in path and writes the terminal to tty,
+// no terminal when tty is nil.
+func fakeClipboardEnv(goos string, env map[string]string, path []string, tty *fakeTTY) clipboardEnv {
+	return clipboardEnv{
+		goos:   goos,
+		getenv: func(k string) string { return env[k] },
+		lookPath: func(name string) (string, error) {
+			for _, p := range path {
+				if p == name {
+					return "/usr/bin/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		},
+		tty: func() (io.WriteCloser, error) {
+			if tty == nil {
+				return nil, errors.New("no tty")
+			}
+			tty.opened++
+			return tty, nil
+		},
+	}
+}
+
+func Test_clipboardEnv_clipboards(t *testing.T) {
+	tty := &fakeTTY{}
+	tests := []struct {
+		name   string
+		method string
+		env    clipboardEnv
+		want   string
+	}{
+		{"darwin", "", fakeClipboardEnv("darwin", nil, []string{"pbcopy"}, tty), "pbcopy"},
+		{"wayland", "auto", fakeClipboardEnv("linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, []string{"wl-copy", "xclip"}, tty), "wl-copy"},
+		{"x11 xsel", "auto", fakeClipboardEnv("linux", map[string]string{"DISPLAY": ":0"}, []string{"xsel"}, tty), "xsel"},
+		{"ssh", "auto", fakeClipboardEnv("linux", map[string]string{"DISPLAY": ":0", "SSH_TTY": "/dev/pts/1"}, []string{"xclip"}, tty), "osc52 terminal escape sequence"},
+		{"headless tries the terminal", "auto", fakeClipboardEnv("linux", nil, []string{"xclip"}, nil), "osc52 terminal escape sequence"},
+		{"forced", "xclip", fakeClipboardEnv("darwin", nil, nil, tty), "xclip"},
+		{"file", "file", fakeClipboardEnv("darwin", nil, []string{"pbcopy"}, tty), "file /tmp/clip.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.env.clipboards(clipboardConfig{Method: tt.method, File: "/tmp/clip.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got[0].String() != tt.want {
+				t.Errorf("first clipboard = %s, want %s", got[0], tt.want)
+			}
+		})
+	}
+	if _, err := (clipboardEnv{}).clipboards(clipboardConfig{Method: "carrier-pigeon"}); err == nil {
+		t.Error("unknown method accepted")
+	}
+	if tty.opened != 0 {
+		t.Errorf("tty opened %d time(s) before any copy", tty.opened)
+	}
+}
+
+func Test_osc52Clipboard(t *testing.T) {
+	var (
+		tty fakeTTY
+		out bytes.Buffer
+	)
+	env := fakeClipboardEnv("linux", map[string]string{"TMUX": "/tmp/tmux-1000/default,1,0"}, nil, &tty)
+	if err := env.copy(clipboardConfig{Method: "osc52"}, "hi", &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\033Ptmux;\033\033]52;c;aGk=\a\033\\"; tty.String() != want {
+		t.Errorf("tty = %q, want %q", tty.String(), want)
+	}
+	if !strings.Contains(out.String(), "osc52") {
+		t.Errorf("method not reported: %q", out.String())
+	}
+	if tty.opened != 1 || tty.closed != 1 {
+		t.Errorf("tty opened %d, closed %d time(s), want 1", tty.opened, tty.closed)
+	}
+}
+
+func Test_clipboardEnv_copy_fallback(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "clip.txt")
+	env := fakeClipboardEnv("linux", nil, nil, nil)
+	var out bytes.Buffer
+	if err := env.copy(clipboardConfig{File: name}, "feat: add clipboard\n", &out); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(name); string(b) != "feat: add clipboard\n" {
+		t.Errorf("file = %q", b)
+	}
+	if out.String() != "📋 copied with file "+name+"\n" {
+		t.Errorf("out = %q", out.String())
+	}
+}
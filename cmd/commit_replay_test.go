@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// The commit commands end to end, on a fake repository and the recorded
+// answers of testdata/replay.
+
+func Test_claudeCommit_replay(t *testing.T) {
+	t.Setenv("GIT_EDITOR", "true")
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	repo.diff = fakeDiff("cmd/transport.go", 1, 3)
+	var out bytes.Buffer
+	cmd := newClaudeCommitCommand(repo, &out, replayDeps(t, "claude_commit"))
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	if len(repo.commits) != 1 {
+		t.Fatalf("commits = %q\n%s", repo.commits, out.String())
+	}
+	msg := repo.commits[0]
+	if !strings.HasPrefix(msg, "cmd.dev-") || !strings.Contains(msg, "\n\nMake the generator dependencies injectable\n") {
+		t.Errorf("commit message:\n%s", msg)
+	}
+}
+
+func Test_ollamaCommit_replay(t *testing.T) {
+	t.Setenv("GIT_EDITOR", "true")
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	repo.diff = fakeDiff("cmd/gen_ollama.go", 1, 3)
+	var out bytes.Buffer
+	cmd := newOllamaCommitCommand(repo, &out, replayDeps(t, "ollama_commit"))
+	cmd.SetArgs([]string{"--host", "127.0.0.1:11434", "--candidates", "2"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	if len(repo.commits) != 1 {
+		t.Fatalf("commits = %q\n%s", repo.commits, out.String())
+	}
+	if msg := repo.commits[0]; !strings.Contains(msg, "\n\nPass the http client to the ollama generator\n") {
+		t.Errorf("commit message:\n%s", msg)
+	}
+}
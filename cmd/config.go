@@ -0,0 +1,606 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// repoConfigName is the per repository configuration file, at the root of
+// the working tree.
+const repoConfigName = ".yag.yaml"
+
+// config is the yag configuration. Layers apply in order: built-in
+// defaults, the user file, the repository file, YAG_* environment variables
+// and at last the flags given on the command line.
+type config struct {
+	Vertex    vertexConfig    `yaml:"vertex"`
+	Ollama    ollamaConfig    `yaml:"ollama"`
+	Anthropic anthropicConfig `yaml:"anthropic"`
+	Tag       tagConfig       `yaml:"tag"`
+	Editor    string          `yaml:"editor"` // overrides core.editor, GIT_EDITOR still wins
+	Srcdir    string          `yaml:"srcdir"` // yag sources, for yag install
+	Diff      diffConfig      `yaml:"diff"`
+	Lint      lintConfig      `yaml:"lint"`
+	Clipboard clipboardConfig `yaml:"clipboard"`
+	Drafts    draftsConfig    `yaml:"drafts"`
+	Prompts   promptsConfig   `yaml:"prompts"`
+	HTTP      httpConfig      `yaml:"http"`
+	Pipeline  pipelineConfig  `yaml:"pipeline"`
+	Trailers  trailersConfig  `yaml:"trailers"`
+	Issues    issuesConfig    `yaml:"issues"`
+
+	src map[string]string // where each key was last set: "file:line", "env NAME" or "flag --name"
+}
+
+type vertexConfig struct {
+	Project  string `yaml:"project"`
+	Location string `yaml:"location"`
+	Model    string `yaml:"model"`
+	// Credentials is the access token provider: auto, gcloud, adc or env.
+	Credentials     string `yaml:"credentials"`
+	CredentialsFile string `yaml:"credentials_file"` // adc file, GOOGLE_APPLICATION_CREDENTIALS by default
+	TokenURL        string `yaml:"token_url"`        // service account token endpoint, the token_uri of the file by default
+}
+
+type ollamaConfig struct {
+	Model string `yaml:"model"` // default model, and model of the extraction pass
+}
+
+// anthropicConfig is the direct access to the Anthropic API, not read
+// from the repository file.
+type anthropicConfig struct {
+	APIKey  string `yaml:"api_key"`  // ANTHROPIC_API_KEY when empty
+	BaseURL string `yaml:"base_url"` // ANTHROPIC_BASE_URL, then the public api when empty
+}
+
+type tagConfig struct {
+	Remote string `yaml:"remote"`
+	Name   string `yaml:"name"` // template of the yag timestamp tags
+	Litt   string `yaml:"litt"` // template of the yag timestamp litt tags
+}
+
+func defaultConfig() config {
+	return config{
+		Vertex: vertexConfig{
+			Project:     "upbeat-task-298823",
+			Location:    "europe-west1",
+			Model:       "claude-3-5-sonnet-v2@20241022",
+			Credentials: "auto",
+		},
+		Ollama:    ollamaConfig{Model: "llama3.2:3b"},
+		Tag:       tagConfig{Remote: "github", Name: defaultTagName, Litt: defaultTagLitt},
+		Srcdir:    filepath.Join("~", "i", "wd", "yag"),
+		Diff:      diffConfig{Budget: 8000, Chunk: 3000},
+		Lint:      lintConfig{SubjectMax: 72, BodyWidth: 72},
+		Clipboard: clipboardConfig{Method: "auto"},
+		Drafts:    draftsConfig{Keep: 20},
+		Prompts:   promptsConfig{Recent: 10},
+		HTTP:      httpConfig{Timeout: 5 * time.Minute, Retries: 3, Backoff: time.Second, MaxBackoff: 30 * time.Second},
+		Pipeline:  pipelineConfig{Stages: slices.Clone(defaultStages)},
+		Trailers:  trailersConfig{Refs: true},
+		Issues:    issuesConfig{Branch: slices.Clone(defaultBranchPatterns)},
+	}
+}
+
+// flagKeys binds flags to the keys they override. A command having one of
+// these flags gets its default from the configuration.
+var flagKeys = map[string]string{
+	"vx-project":   "vertex.project",
+	"vx-location":  "vertex.location",
+	"vx-model":     "vertex.model",
+	"ollama-model": "ollama.model",
+	"remote":       "tag.remote",
+	"signoff":      "trailers.signoff",
+}
+
+// userConfigPath is $YAG_CONFIG, or yag/config.yaml in $XDG_CONFIG_HOME,
+// ~/.config by default.
+func userConfigPath() (string, error) {
+	if name := os.Getenv("YAG_CONFIG"); name != "" {
+		return name, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "yag", "config.yaml"), nil
+}
+
+// repoConfigPath is .yag.yaml at the root of the repository, "" outside of
+// a repository.
+func repoConfigPath(repo Repo) string {
+	root, err := repo.Root()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(root, repoConfigName)
+}
+
+// loadConfig merges the configuration layers, then sets the flags of cmd
+// missing from the command line to their configured value. cmd may be nil.
+func loadConfig(repo Repo, cmd *cobra.Command) (config, error) {
+	cfg := defaultConfig()
+	cfg.src = map[string]string{}
+	user, err := userConfigPath()
+	if err != nil {
+		return cfg, err
+	}
+	if err = cfg.mergeFile(user, true); err != nil {
+		return cfg, err
+	}
+	if name := repoConfigPath(repo); name != "" {
+		if err = cfg.mergeFile(name, false); err != nil {
+			return cfg, err
+		}
+	}
+	if err = cfg.mergeEnv(); err != nil {
+		return cfg, err
+	}
+	if cmd != nil {
+		if err = cfg.bind(cmd); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, cfg.validate()
+}
+
+// trustedKeys are only read from the user file, the environment and the
+// flags: a cloned repository could leak the credentials or run commands
+// with them. issues.cache is only trusted as a url.
+var trustedKeys = []string{
+	"editor", "srcdir",
+	"anthropic.api_key", "anthropic.base_url",
+	"vertex.credentials", "vertex.credentials_file", "vertex.token_url",
+}
+
+// trustedOnly tells whether key set to value needs a trusted layer.
+func trustedOnly(key, value string) bool {
+	if key == "issues.cache" {
+		u, err := url.Parse(value)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+	}
+	return slices.Contains(trustedKeys, key)
+}
+
+// mergeFile sets the keys present in a yaml file, a missing file is empty.
+// The keys needing trust are ignored, with a warning, in an untrusted file.
+func (c *config) mergeFile(name string, trusted bool) error {
+	b, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return c.mergeNode(name, "", doc.Content[0], reflect.ValueOf(c).Elem(), trusted)
+}
+
+func (c *config) mergeNode(name, key string, n *yaml.Node, v reflect.Value, trusted bool) error {
+	if v.Kind() == reflect.Struct {
+		if n.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s:%d: %s: want a mapping", name, n.Line, keyOr(key, "top level"))
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			sub := joinKey(key, k.Value)
+			f, ok := fieldByName(v, k.Value)
+			if !ok {
+				return fmt.Errorf("%s:%d: unknown key %q", name, k.Line, sub)
+			}
+			if err := c.mergeNode(name, sub, n.Content[i+1], f, trusted); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if n.Tag == "!!null" {
+		return nil
+	}
+	if !trusted && trustedOnly(key, n.Value) {
+		warn("%s:%d: %s ignored, set it in the user configuration", name, n.Line, key)
+		return nil
+	}
+	var err error
+	switch {
+	case n.Kind == yaml.ScalarNode:
+		err = setValue(v, n.Value)
+	case n.Kind == yaml.SequenceNode && v.Kind() == reflect.Slice:
+		items := make([]string, 0, len(n.Content))
+		for _, item := range n.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("%s:%d: %s: want a list of strings", name, item.Line, key)
+			}
+			items = append(items, item.Value)
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		err = fmt.Errorf("want %s", kindName(v))
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%d: %s: %w", name, n.Line, key, err)
+	}
+	c.src[key] = fmt.Sprintf("%s:%d", name, n.Line)
+	return nil
+}
+
+// mergeEnv sets the keys from YAG_<KEY> variables, YAG_VERTEX_PROJECT for
+// vertex.project. Lists are comma separated.
+func (c *config) mergeEnv() error {
+	for _, key := range configKeys() {
+		name := envName(key)
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		v, _ := c.field(key)
+		if err := setValue(v, s); err != nil {
+			return fmt.Errorf("env %s: %s: %w", name, key, err)
+		}
+		c.src[key] = "env " + name
+	}
+	return nil
+}
+
+// bind sets the flags of cmd to the configuration when they are not on the
+// command line, and the configuration to the flags when they are.
+func (c *config) bind(cmd *cobra.Command) error {
+	for name, key := range flagKeys {
+		fl := cmd.Flags().Lookup(name)
+		if fl == nil {
+			continue
+		}
+		v, _ := c.field(key)
+		if fl.Changed {
+			if err := setValue(v, fl.Value.String()); err != nil {
+				return fmt.Errorf("flag --%s: %w", name, err)
+			}
+			c.src[key] = "flag --" + name
+			continue
+		}
+		if err := fl.Value.Set(formatValue(v)); err != nil {
+			return fmt.Errorf("%s: %s: %w", c.source(key), key, err)
+		}
+	}
+	return nil
+}
+
+// validate checks what the types do not.
+func (c config) validate() error {
+	var errs []error
+	check := func(key string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %s: %w", c.source(key), key, err))
+	}
+	for _, key := range []string{"diff.budget", "diff.chunk", "lint.subject_max", "lint.body_width", "drafts.keep", "prompts.recent", "http.timeout", "http.retries", "http.backoff", "http.max_backoff"} {
+		if v, _ := c.field(key); v.Int() < 0 {
+			check(key, fmt.Errorf("must not be negative, got %s", formatValue(v)))
+		}
+	}
+	for _, key := range []string{"lint.disable", "lint.warn"} {
+		v, _ := c.field(key)
+		for _, r := range v.Interface().([]string) {
+			if !slices.Contains(lintRules, r) {
+				check(key, fmt.Errorf("unknown rule %q, want one of %s", r, strings.Join(lintRules, ", ")))
+			}
+		}
+	}
+	for _, name := range promptNames {
+		key := "prompts." + name
+		if v, _ := c.field(key); v.String() != "" {
+			if _, err := parsePrompt(name, v.String()); err != nil {
+				check(key, err)
+			}
+		}
+	}
+	for _, s := range c.Pipeline.Stages {
+		if !slices.Contains(pipelineStages, s) {
+			check("pipeline.stages", fmt.Errorf("unknown stage %q, want one of %s", s, strings.Join(pipelineStages, ", ")))
+		}
+	}
+	for _, rule := range c.Pipeline.Cleanup {
+		if _, err := parseSubst(rule); err != nil {
+			check("pipeline.cleanup", err)
+		}
+	}
+	for _, t := range c.Pipeline.Trailers {
+		if !trailerLine.MatchString(t) {
+			check("pipeline.trailers", fmt.Errorf("trailer %q, want \"Token: value\"", t))
+		}
+	}
+	for _, key := range []string{"tag.name", "tag.litt"} {
+		if v, _ := c.field(key); v.String() != "" {
+			if _, err := renderTag(key, v.String(), sampleTagData); err != nil {
+				check(key, err)
+			}
+		}
+	}
+	for _, p := range c.Issues.Branch {
+		if _, err := regexp.Compile(p); err != nil {
+			check("issues.branch", err)
+		}
+	}
+	for _, co := range c.Trailers.Roster {
+		if !nameAddr.MatchString(co) {
+			check("trailers.roster", fmt.Errorf("co-author %q, want \"Name <email>\"", co))
+		}
+	}
+	if c.Anthropic.BaseURL != "" {
+		if u, err := url.Parse(c.Anthropic.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			check("anthropic.base_url", fmt.Errorf("%q, want an http or https url", c.Anthropic.BaseURL))
+		}
+	}
+	if !slices.Contains(credentialProviders, c.Vertex.Credentials) {
+		check("vertex.credentials", fmt.Errorf("unknown provider %q, want one of %s", c.Vertex.Credentials, strings.Join(credentialProviders, ", ")))
+	}
+	if !slices.Contains(clipboardMethods, c.Clipboard.Method) {
+		check("clipboard.method", fmt.Errorf("unknown method %q, want one of %s", c.Clipboard.Method, strings.Join(clipboardMethods, ", ")))
+	}
+	for _, key := range []string{"vertex.project", "vertex.location", "vertex.model", "ollama.model", "tag.remote"} {
+		if v, _ := c.field(key); v.String() == "" {
+			check(key, errors.New("must not be empty"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// source tells where key was set.
+func (c config) source(key string) string {
+	if s, ok := c.src[key]; ok {
+		return s
+	}
+	return "default"
+}
+
+// srcDir is the srcdir key with ~ expanded.
+func (c config) srcDir() (string, error) {
+	return expandHome(c.Srcdir)
+}
+
+// expandHome replaces a leading ~ with the home directory.
+func expandHome(name string) (string, error) {
+	rest, ok := strings.CutPrefix(name, "~")
+	if !ok {
+		return name, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return home + rest, nil
+}
+
+// field returns the value of a dotted key, false when key is not a leaf.
+func (c *config) field(key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	for _, name := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return v, false
+		}
+		f, ok := fieldByName(v, name)
+		if !ok {
+			return v, false
+		}
+		v = f
+	}
+	return v, v.Kind() != reflect.Struct
+}
+
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		if yamlName(t.Field(i)) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return name
+}
+
+// configKeys lists the dotted keys of the configuration leaves.
+func configKeys() []string {
+	var (
+		keys []string
+		walk func(prefix string, t reflect.Type)
+	)
+	walk = func(prefix string, t reflect.Type) {
+		for i := range t.NumField() {
+			name := yamlName(t.Field(i))
+			switch {
+			case name == "":
+			case t.Field(i).Type.Kind() == reflect.Struct:
+				walk(joinKey(prefix, name), t.Field(i).Type)
+			default:
+				keys = append(keys, joinKey(prefix, name))
+			}
+		}
+	}
+	walk("", reflect.TypeOf(config{}))
+	return keys
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func keyOr(key, s string) string {
+	if key == "" {
+		return s
+	}
+	return key
+}
+
+func envName(key string) string {
+	return "YAG_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// setValue parses s into v, lists being comma separated.
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+		return nil
+	}
+	var err error
+	switch v.Kind() {
+	case reflect.Int:
+		var n int
+		n, err = strconv.Atoi(strings.TrimSpace(s))
+		v.SetInt(int64(n))
+	case reflect.Int64: // time.Duration, the only int64
+		var d time.Duration
+		d, err = time.ParseDuration(strings.TrimSpace(s))
+		v.SetInt(int64(d))
+	case reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+		v.SetFloat(f)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(strings.TrimSpace(s))
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported %s value", v.Kind())
+	}
+	if err != nil {
+		return fmt.Errorf("want %s, got %q", kindName(v), s)
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func kindName(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Int:
+		return "an integer"
+	case reflect.Int64:
+		return "a duration"
+	case reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Struct:
+		return "a mapping"
+	}
+	return "a string"
+}
+
+// setConfigFile sets key to value in the yaml file name, keeping the rest
+// of the file.
+func setConfigFile(name, key, value string) error {
+	scratch := defaultConfig()
+	v, ok := scratch.field(key)
+	if !ok {
+		return fmt.Errorf("unknown key %q", key)
+	}
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	scratch.src = map[string]string{key: "value"}
+	if err := scratch.validate(); err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	b, err := os.ReadFile(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	n := doc.Content[0]
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		if n.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s:%d: %s: want a mapping", name, n.Line, keyOr(strings.Join(parts[:i], "."), "top level"))
+		}
+		var next *yaml.Node
+		for j := 0; j+1 < len(n.Content); j += 2 {
+			if n.Content[j].Value == part {
+				next = n.Content[j+1]
+			}
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, next)
+		}
+		if i == len(parts)-1 {
+			*next = valueNode(v)
+		}
+		n = next
+	}
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, out, 0644)
+}
+
+func valueNode(v reflect.Value) yaml.Node {
+	switch v.Kind() {
+	case reflect.Slice:
+		n := yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range v.Interface().([]string) {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+		}
+		return n
+	case reflect.String:
+		var n yaml.Node
+		n.SetString(v.String())
+		return n
+	}
+	return yaml.Node{Kind: yaml.ScalarNode, Value: formatValue(v)}
+}
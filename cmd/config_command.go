@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// secretKeys are not shown by config list.
+var secretKeys = []string{"anthropic.api_key"}
+
+func newConfigCommand(repo Repo, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "show and edit the yag configuration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		newConfigGetCommand(repo, out),
+		newConfigSetCommand(repo, out),
+		newConfigListCommand(repo, out),
+		newConfigPathCommand(repo, out),
+	)
+	return cmd
+}
+
+func newConfigGetCommand(repo Repo, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get key",
+		Short: "print the value of a key, like vertex.project",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(repo, nil)
+			if err != nil {
+				return err
+			}
+			v, ok := cfg.field(args[0])
+			if !ok {
+				return fmt.Errorf("unknown key %q", args[0])
+			}
+			fmt.Fprintln(out, formatValue(v))
+			return nil
+		},
+	}
+}
+
+func newConfigSetCommand(repo Repo, out io.Writer) *cobra.Command {
+	var repoOpt *bool
+	cmd := &cobra.Command{
+		Use:   "set key value",
+		Short: "set a key in the user configuration, lists are comma separated",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := userConfigPath()
+			if err != nil {
+				return err
+			}
+			if *repoOpt {
+				if name = repoConfigPath(repo); name == "" {
+					return errors.New("not in a git repository")
+				}
+				if trustedOnly(args[0], args[1]) {
+					return fmt.Errorf("%s is not read from %s, set it in the user configuration", args[0], repoConfigName)
+				}
+			}
+			if err = setConfigFile(name, args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%s set in %s\n", args[0], name)
+			return nil
+		},
+	}
+	repoOpt = cmd.Flags().Bool("repo", false, "set the key in the repository "+repoConfigName+" instead")
+	return cmd
+}
+
+func newConfigListCommand(repo Repo, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list the keys, their value and where it comes from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(repo, nil)
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			for _, key := range configKeys() {
+				v, _ := cfg.field(key)
+				value := formatValue(v)
+				if slices.Contains(secretKeys, key) && value != "" {
+					value = "********" // config get prints it
+				}
+				fmt.Fprintf(tw, "%s\t%s\t# %s, %s\n", key, value, cfg.source(key), envName(key))
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func newConfigPathCommand(repo Repo, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "path",
+		Short: "print the user and repository configuration files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := userConfigPath()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "user:", name)
+			if name = repoConfigPath(repo); name != "" {
+				fmt.Fprintln(out, "repo:", name)
+			}
+			return nil
+		},
+	}
+}
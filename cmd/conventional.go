@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// conventionalTypes are the commit types of the Conventional Commits
+// specification and of the Angular convention it derives from.
+var conventionalTypes = []string{
+	"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test",
+}
+
+var (
+	conventionalHeader = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^()\s]+)\))?(!)?: (\S.*)$`)
+	conventionalFooter = regexp.MustCompile(`^([A-Za-z][\w-]*|BREAKING CHANGE)(?:: | #)(.*)$`)
+)
+
+// tagFooter carries the yag timestamp tag when the subject line belongs to
+// the commit convention.
+const tagFooter = "Yag-Tag"
+
+type conventionalCommit struct {
+	typ      string
+	scope    string
+	breaking bool
+	subject  string
+	body     string
+	footers  [][2]string // token, value
+}
+
+// parseConventional validates msg against the Conventional Commits 1.0.0
+// grammar: type(scope)!: subject, then an optional body and footers each
+// separated by a blank line.
+func parseConventional(msg string) (conventionalCommit, error) {
+	var c conventionalCommit
+	msg = strings.TrimSpace(msg)
+	header, rest, _ := strings.Cut(msg, "\n")
+	m := conventionalHeader.FindStringSubmatch(header)
+	if m == nil {
+		return c, fmt.Errorf("header %q is not \"type(scope): subject\"", header)
+	}
+	c.typ, c.scope, c.breaking, c.subject = m[1], m[2], m[3] == "!", m[4]
+	if !isConventionalType(c.typ) {
+		return c, fmt.Errorf("type %q is not one of %s", c.typ, strings.Join(conventionalTypes, ", "))
+	}
+	if rest == "" {
+		return c, nil
+	}
+	if !strings.HasPrefix(rest, "\n") {
+		return c, fmt.Errorf("the header must be followed by a blank line")
+	}
+	for _, line := range strings.Split(rest, "\n") {
+		token, _, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(token, "BREAKING CHANGE") && token != "BREAKING CHANGE" {
+			return c, fmt.Errorf("footer %q must be uppercase", token)
+		}
+	}
+	paragraphs := strings.Split(strings.TrimSpace(rest), "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	if footers, ok := parseFooters(last); ok {
+		c.footers = footers
+		paragraphs = paragraphs[:len(paragraphs)-1]
+	}
+	c.body = strings.Join(paragraphs, "\n\n")
+	for _, f := range c.footers {
+		if strings.EqualFold(f[0], "BREAKING CHANGE") || strings.EqualFold(f[0], "BREAKING-CHANGE") {
+			if f[0] != strings.ToUpper(f[0]) {
+				return c, fmt.Errorf("footer %q must be uppercase", f[0])
+			}
+			c.breaking = true
+		}
+	}
+	return c, nil
+}
+
+func isConventionalType(typ string) bool {
+	for _, t := range conventionalTypes {
+		if strings.EqualFold(t, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFooters reads a paragraph made only of "Token: value" or
+// "Token #value" lines, indented lines continuing the previous value.
+func parseFooters(paragraph string) ([][2]string, bool) {
+	var footers [][2]string
+	for _, line := range strings.Split(paragraph, "\n") {
+		if m := conventionalFooter.FindStringSubmatch(line); m != nil {
+			footers = append(footers, [2]string{m[1], m[2]})
+			continue
+		}
+		if len(footers) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			footers[len(footers)-1][1] += "\n" + line
+			continue
+		}
+		return nil, false
+	}
+	return footers, len(footers) > 0
+}
+
+// footerValue returns the value of the first footer with token.
+func footerValue(msg, token string) string {
+	paragraphs := strings.Split(strings.TrimSpace(msg), "\n\n")
+	footers, _ := parseFooters(paragraphs[len(paragraphs)-1])
+	for _, f := range footers {
+		if strings.EqualFold(f[0], token) {
+			return strings.TrimSpace(f[1])
+		}
+	}
+	return ""
+}
+
+// appendFooter adds "token: value" to the footers of msg.
+func appendFooter(msg, token, value string) string {
+	msg = strings.TrimRight(msg, "\n")
+	paragraphs := strings.Split(msg, "\n\n")
+	sep := "\n\n"
+	if _, ok := parseFooters(paragraphs[len(paragraphs)-1]); ok && len(paragraphs) > 1 {
+		sep = "\n"
+	}
+	return msg + sep + token + ": " + value + "\n"
+}
+
+// inferScope names the deepest directory holding all the paths, "" when
+// they do not share one.
+func inferScope(paths []string) string {
+	var common []string
+	for i, p := range paths {
+		dir := strings.Split(path.Dir(strings.TrimSuffix(p, "/")), "/")
+		if dir[0] == "." {
+			return ""
+		}
+		if i == 0 {
+			common = dir
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(dir) && common[n] == dir[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if len(common) == 0 {
+		return ""
+	}
+	return common[len(common)-1]
+}
+
+func conventionalSystemPrompt(scope string) string {
+	scopeHint := "Omit the scope unless one area of the code is obviously concerned."
+	if scope != "" {
+		scopeHint = fmt.Sprintf("The changed files live in %q, use it as the scope unless a narrower one is obvious.", scope)
+	}
+	return fmt.Sprintf(`Answer with a commit message following the Conventional Commits 1.0.0
+specification and nothing else, no preamble and no markdown fences.
+
+The first line is "type(scope): subject" where type is one of %s.
+%s
+The subject is imperative, lower case, without final period, and the whole
+first line is at most 72 characters. Add "!" after the scope for breaking
+changes.
+
+Then a blank line and a body explaining what changed and why, wrapped at 72
+columns. End with footers such as "BREAKING CHANGE: description" or
+"Refs: #123" when relevant, separated from the body by a blank line.`,
+		strings.Join(conventionalTypes, ", "), scopeHint)
+}
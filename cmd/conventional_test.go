@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func Test_parseConventional(t *testing.T) {
+	for _, tt := range []struct {
+		msg      string
+		wantErr  bool
+		typ      string
+		scope    string
+		breaking bool
+		footers  int
+	}{
+		{msg: "feat(cmd): add status parser", typ: "feat", scope: "cmd"},
+		{msg: "fix: handle quoted paths\n\nBody line.\n\nRefs: #12\nReviewed-by: Z", typ: "fix", footers: 2},
+		{msg: "refactor(git)!: drop gitCli", typ: "refactor", scope: "git", breaking: true},
+		{msg: "feat: x\n\nBREAKING CHANGE: the flag is gone\n  and continues here", typ: "feat", breaking: true, footers: 1},
+		{msg: "feat: x\n\nbreaking change: lower", wantErr: true},
+		{msg: "Add status parser", wantErr: true},
+		{msg: "feature(cmd): add", wantErr: true},
+		{msg: "feat(cmd):add", wantErr: true},
+		{msg: "feat: add\nno blank line", wantErr: true},
+		{msg: "```\nfeat: add\n```", wantErr: true},
+	} {
+		c, err := parseConventional(tt.msg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseConventional(%q) err = %v", tt.msg, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if c.typ != tt.typ || c.scope != tt.scope || c.breaking != tt.breaking || len(c.footers) != tt.footers {
+			t.Errorf("parseConventional(%q) = %+v", tt.msg, c)
+		}
+	}
+}
+
+func Test_inferScope(t *testing.T) {
+	for _, tt := range []struct {
+		paths []string
+		want  string
+	}{
+		{[]string{"cmd/root.go", "cmd/status.go"}, "cmd"},
+		{[]string{"internal/git/a.go", "internal/git/b/c.go"}, "git"},
+		{[]string{"internal/git/a.go", "internal/llm/b.go"}, "internal"},
+		{[]string{"cmd/root.go", "go.mod"}, ""},
+		{[]string{"cmd/", "cmd/x.go"}, ""},
+		{nil, ""},
+	} {
+		if got := inferScope(tt.paths); got != tt.want {
+			t.Errorf("inferScope(%q) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func Test_appendFooter(t *testing.T) {
+	got := appendFooter("feat: x\n\nbody\n", tagFooter, "root.dev-1")
+	if got != "feat: x\n\nbody\n\nYag-Tag: root.dev-1\n" {
+		t.Errorf("got %q", got)
+	}
+	got = appendFooter("feat: x\n\nbody\n\nRefs: #1", tagFooter, "root.dev-1")
+	if got != "feat: x\n\nbody\n\nRefs: #1\nYag-Tag: root.dev-1\n" {
+		t.Errorf("got %q", got)
+	}
+	if v := footerValue(got, "yag-tag"); v != "root.dev-1" {
+		t.Errorf("footerValue = %q", v)
+	}
+}
+
+// answersGenerator replies with its answers in turn.
+type answersGenerator struct {
+	mu      sync.Mutex
+	answers []string
+	reqs    []genRequest
+}
+
+func (g *answersGenerator) Generate(ctx context.Context, req genRequest) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.reqs = append(g.reqs, req)
+	a := g.answers[0]
+	g.answers = g.answers[1:]
+	return a, nil
+}
+
+func Test_commitFlow_conventionalRetry(t *testing.T) {
+	repo := newFakeRepo()
+	gen := &answersGenerator{answers: []string{"Here is a commit message: add stuff", "feat(cmd): add stuff"}}
+	flow := commitFlow{
+		repo:  repo,
+		gen:   gen,
+		out:   &strings.Builder{},
+		style: styleConventional,
+		debug: zap.NewNop(),
+	}
+	msg, err := flow.generate(context.Background(), fakeDiff("cmd/repo.go", 1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "feat(cmd): add stuff" || len(gen.reqs) != 2 {
+		t.Fatalf("msg = %q after %d requests", msg, len(gen.reqs))
+	}
+	if !strings.Contains(gen.reqs[0].system, `"cmd"`) {
+		t.Errorf("scope not in system prompt:\n%s", gen.reqs[0].system)
+	}
+	if !strings.Contains(gen.reqs[1].prompt, "Here is a commit message") {
+		t.Errorf("retry prompt misses the previous answer:\n%s", gen.reqs[1].prompt)
+	}
+}
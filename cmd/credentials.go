@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var credentialProviders = []string{"auto", "gcloud", "adc", "env"}
+
+const (
+	// tokenEnv holds a ready access token for the env provider.
+	tokenEnv = "GOOGLE_OAUTH_ACCESS_TOKEN"
+	// googleTokenURL is the token endpoint when the adc file has none.
+	googleTokenURL     = "https://oauth2.googleapis.com/token"
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	// gcloudTokenLifetime is how long a gcloud token is reused, gcloud does
+	// not tell when it expires.
+	gcloudTokenLifetime = 30 * time.Minute
+	// tokenLeeway renews the tokens this long before they expire.
+	tokenLeeway = time.Minute
+)
+
+// accessToken is a bearer token and when it expires, zero for never.
+type accessToken struct {
+	value  string
+	expiry time.Time
+}
+
+// tokenProvider fetches a new access token.
+type tokenProvider interface {
+	fetch(ctx context.Context) (accessToken, error)
+	String() string // names the provider in errors
+}
+
+// newTokenSource returns the configured provider, cached. Nothing is read
+// before the first token is asked, other providers than vertex never do.
+func newTokenSource(cfg vertexConfig, client *http.Client) tokenSource {
+	var p tokenProvider
+	switch cfg.Credentials {
+	case "gcloud":
+		p = gcloudToken{}
+	case "adc":
+		p = adcToken{file: cfg.CredentialsFile, tokenURL: cfg.TokenURL, client: client}
+	case "env":
+		p = envToken{name: tokenEnv}
+	default: // auto
+		p = autoToken{cfg: cfg, client: client}
+	}
+	return &cachedToken{provider: p, now: time.Now}
+}
+
+// cachedToken reuses the token of its provider until it expires.
+type cachedToken struct {
+	provider tokenProvider
+	now      func() time.Time
+
+	mu  sync.Mutex
+	cur accessToken
+}
+
+func (c *cachedToken) Token(ctx context.Context) (string, error) {
+	c.mu.Lock() // the candidates ask at once, one fetch is enough
+	defer c.mu.Unlock()
+	if c.cur.value != "" && (c.cur.expiry.IsZero() || c.now().Add(tokenLeeway).Before(c.cur.expiry)) {
+		return c.cur.value, nil
+	}
+	t, err := c.provider.fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s credentials: %w", c.provider, err)
+	}
+	c.cur = t
+	return t.value, nil
+}
+
+// gcloudToken asks gcloud for the access token of the active account.
+type gcloudToken struct{}
+
+func (gcloudToken) String() string { return "gcloud" }
+
+func (g gcloudToken) Token(ctx context.Context) (string, error) {
+	t, err := g.fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s credentials: %w", g, err)
+	}
+	return t.value, nil
+}
+
+func (gcloudToken) fetch(ctx context.Context) (accessToken, error) {
+	var stderr strings.Builder
+	c := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token")
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return accessToken{}, errors.New("gcloud is not installed, set vertex.credentials to adc or env")
+	}
+	if err != nil {
+		return accessToken{}, fmt.Errorf("gcloud auth print-access-token: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return accessToken{}, errors.New("gcloud auth print-access-token printed no token")
+	}
+	return accessToken{value: token, expiry: time.Now().Add(gcloudTokenLifetime)}, nil
+}
+
+// envToken reads a token obtained elsewhere from the environment.
+type envToken struct{ name string }
+
+func (e envToken) String() string { return "env " + e.name }
+
+func (e envToken) fetch(context.Context) (accessToken, error) {
+	token := strings.TrimSpace(os.Getenv(e.name))
+	if token == "" {
+		return accessToken{}, fmt.Errorf("%s is not set", e.name)
+	}
+	return accessToken{value: token}, nil
+}
+
+// adcToken exchanges Application Default Credentials for an access token:
+// a JWT signed with the key of a service account, or the refresh token of
+// gcloud auth application-default login.
+type adcToken struct {
+	file     string // GOOGLE_APPLICATION_CREDENTIALS, then the gcloud default, when empty
+	tokenURL string // of service accounts, the token_uri of the file when empty
+	client   *http.Client
+}
+
+func (a adcToken) String() string { return "adc" }
+
+// adcFile is a service_account or authorized_user credentials file.
+type adcFile struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// adcPath is the credentials file to read.
+func (a adcToken) adcPath() (string, error) {
+	if a.file != "" {
+		return expandHome(a.file)
+	}
+	if name := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); name != "" {
+		return name, nil
+	}
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config", "gcloud")
+	}
+	return filepath.Join(dir, "application_default_credentials.json"), nil
+}
+
+func (a adcToken) fetch(ctx context.Context) (accessToken, error) {
+	name, err := a.adcPath()
+	if err != nil {
+		return accessToken{}, err
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return accessToken{}, err
+	}
+	var f adcFile
+	if err = json.Unmarshal(b, &f); err != nil {
+		return accessToken{}, fmt.Errorf("%s: %w", name, err)
+	}
+	endpoint := f.TokenURI
+	if endpoint == "" {
+		endpoint = googleTokenURL
+	}
+	form := url.Values{}
+	switch f.Type {
+	case "service_account":
+		if a.tokenURL != "" {
+			endpoint = a.tokenURL
+		}
+		assertion, err := f.assertion(endpoint, time.Now())
+		if err != nil {
+			return accessToken{}, fmt.Errorf("%s: %w", name, err)
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		// the refresh token and the client secret only go where the file
+		// says, never to vertex.token_url
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", f.ClientID)
+		form.Set("client_secret", f.ClientSecret)
+		form.Set("refresh_token", f.RefreshToken)
+	default:
+		return accessToken{}, fmt.Errorf("%s: credentials type %q, want service_account or authorized_user", name, f.Type)
+	}
+	return a.exchange(ctx, endpoint, form)
+}
+
+// assertion is the signed JWT of a service account asking for a
+// cloud-platform token at endpoint.
+func (f adcFile) assertion(endpoint string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(f.PrivateKey))
+	if block == nil {
+		return "", errors.New("private_key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("private_key is a %T, want an RSA key", k)
+		}
+		key = rsaKey
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", fmt.Errorf("private_key: %w", err)
+	}
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": f.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   f.ClientEmail,
+		"scope": cloudPlatformScope,
+		"aud":   endpoint,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// exchange posts the grant to the token endpoint.
+func (a adcToken) exchange(ctx context.Context, endpoint string, form url.Values) (accessToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return accessToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := a.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return accessToken{}, fmt.Errorf("token endpoint: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return accessToken{}, err
+	}
+	var out struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = json.Unmarshal(body, &out); err != nil && res.StatusCode/100 == 2 {
+		return accessToken{}, fmt.Errorf("token endpoint %s: decode %s response: %w", endpoint, res.Status, err)
+	}
+	if res.StatusCode/100 != 2 || out.Error != "" {
+		if out.Error == "" {
+			return accessToken{}, fmt.Errorf("token endpoint %s: %s: %s", endpoint, res.Status, strings.TrimSpace(string(body)))
+		}
+		return accessToken{}, fmt.Errorf("token endpoint %s: %s: %s: %s", endpoint, res.Status, out.Error, out.ErrorDescription)
+	}
+	if out.AccessToken == "" {
+		return accessToken{}, fmt.Errorf("token endpoint %s: no access_token in the response", endpoint)
+	}
+	t := accessToken{value: out.AccessToken}
+	if out.ExpiresIn > 0 {
+		t.expiry = time.Now().Add(time.Duration(out.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+// autoToken takes the first provider set up: a token in the environment,
+// an adc file, then gcloud.
+type autoToken struct {
+	cfg    vertexConfig
+	client *http.Client
+}
+
+func (a autoToken) provider() (tokenProvider, error) {
+	if os.Getenv(tokenEnv) != "" {
+		return envToken{name: tokenEnv}, nil
+	}
+	adc := adcToken{file: a.cfg.CredentialsFile, tokenURL: a.cfg.TokenURL, client: a.client}
+	name, err := adc.adcPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(name); err == nil {
+		return adc, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return gcloudToken{}, nil
+}
+
+func (a autoToken) String() string {
+	p, err := a.provider()
+	if err != nil {
+		return "auto"
+	}
+	return "auto: " + p.String()
+}
+
+func (a autoToken) fetch(ctx context.Context) (accessToken, error) {
+	p, err := a.provider()
+	if err != nil {
+		return accessToken{}, err
+	}
+	return p.fetch(ctx)
+}
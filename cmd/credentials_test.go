@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTokenEndpoint checks the service account assertions against key and
+// answers with numbered tokens, or with status and body when set.
+func fakeTokenEndpoint(t *testing.T, key *rsa.PrivateKey, status int, body string) (*httptest.Server, *int) {
+	t.Helper()
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if status != 0 {
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant_type = %q", got)
+		}
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		if len(parts) != 3 {
+			t.Fatalf("assertion = %q", r.PostForm.Get("assertion"))
+		}
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+			t.Errorf("signature: %v", err)
+		}
+		var claims map[string]any
+		b, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		if err := json.Unmarshal(b, &claims); err != nil {
+			t.Fatal(err)
+		}
+		if claims["iss"] != "yag@test.iam.gserviceaccount.com" || claims["scope"] != cloudPlatformScope || claims["aud"] != "http://"+r.Host+"/token" {
+			t.Errorf("claims = %v", claims)
+		}
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3599,"token_type":"Bearer"}`, calls)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// serviceAccountFile writes the credentials file of key.
+func serviceAccountFile(t *testing.T, key *rsa.PrivateKey, tokenURI string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(adcFile{
+		Type:         "service_account",
+		ClientEmail:  "yag@test.iam.gserviceaccount.com",
+		PrivateKeyID: "key-1",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:     tokenURI,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "sa.json")
+	if err = os.WriteFile(name, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func Test_adcToken_cached(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, calls := fakeTokenEndpoint(t, key, 0, "")
+	t.Setenv(tokenEnv, "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", serviceAccountFile(t, key, srv.URL+"/token"))
+
+	now := time.Now()
+	src := newTokenSource(vertexConfig{Credentials: "auto"}, srv.Client()).(*cachedToken)
+	src.now = func() time.Time { return now }
+	for range 2 {
+		if got, err := src.Token(context.Background()); err != nil || got != "token-1" {
+			t.Fatalf("Token() = %q, %v", got, err)
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("%d calls to the token endpoint, want the token cached", *calls)
+	}
+	now = now.Add(time.Hour)
+	if got, err := src.Token(context.Background()); err != nil || got != "token-2" {
+		t.Errorf("Token() after expiry = %q, %v", got, err)
+	}
+}
+
+func Test_adcToken_authorizedUser(t *testing.T) {
+	var got url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		got = r.PostForm
+		fmt.Fprint(w, `{"access_token":"user-token","expires_in":3599}`)
+	}))
+	t.Cleanup(srv.Close)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("refresh token sent to vertex.token_url")
+	}))
+	t.Cleanup(other.Close)
+	b, _ := json.Marshal(adcFile{Type: "authorized_user", ClientID: "id", ClientSecret: "secret", RefreshToken: "refresh", TokenURI: srv.URL})
+	name := filepath.Join(t.TempDir(), "adc.json")
+	if err := os.WriteFile(name, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	token, err := adcToken{file: name, tokenURL: other.URL, client: srv.Client()}.fetch(context.Background())
+	if err != nil || token.value != "user-token" {
+		t.Fatalf("fetch() = %q, %v", token.value, err)
+	}
+	if got.Get("grant_type") != "refresh_token" || got.Get("refresh_token") != "refresh" {
+		t.Errorf("form = %v", got)
+	}
+}
+
+func Test_tokenSource_errors(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := fakeTokenEndpoint(t, key, http.StatusBadRequest, `{"error":"invalid_grant","error_description":"Invalid JWT Signature."}`)
+	sa := serviceAccountFile(t, key, "https://oauth2.example/token")
+	t.Setenv("PATH", t.TempDir()) // no gcloud
+
+	tests := []struct {
+		name    string
+		cfg     vertexConfig
+		wantErr string
+	}{
+		{"token endpoint", vertexConfig{Credentials: "adc", CredentialsFile: sa, TokenURL: srv.URL}, "adc credentials: token endpoint " + srv.URL + ": 400 Bad Request: invalid_grant: Invalid JWT Signature."},
+		{"no file", vertexConfig{Credentials: "adc", CredentialsFile: filepath.Join(t.TempDir(), "nope.json")}, "adc credentials: open "},
+		{"env", vertexConfig{Credentials: "env"}, "env " + tokenEnv + " credentials: " + tokenEnv + " is not set"},
+		{"gcloud", vertexConfig{Credentials: "gcloud"}, "gcloud credentials: gcloud is not installed"},
+		{"auto", vertexConfig{Credentials: "auto", CredentialsFile: filepath.Join(t.TempDir(), "nope.json")}, "auto: gcloud credentials: gcloud is not installed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tokenEnv, "")
+			_, err := newTokenSource(tt.cfg, srv.Client()).Token(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// diffConfig is the "diff" section of the repository configuration.
+type diffConfig struct {
+	// Budget is the estimated number of tokens of diff sent in a single
+	// prompt. Bigger diffs are split in chunks summarised one by one.
+	Budget int `yaml:"budget"`
+	// Chunk is the estimated number of tokens of a summarised chunk.
+	Chunk int `yaml:"chunk"`
+	// Exclude lists globs of files only described by their line counts, in
+	// addition to the default lockfile, vendored and generated ones. A
+	// pattern without slash matches the base name, dir/** matches a tree.
+	Exclude []string `yaml:"exclude"`
+}
+
+var defaultDiffExclude = []string{
+	"go.sum",
+	"*.lock",
+	"package-lock.json",
+	"pnpm-lock.yaml",
+	"*.min.js",
+	"*.min.css",
+	"*.pb.go",
+	"*.svg",
+	"**/vendor/**",
+	"**/node_modules/**",
+}
+
+func (c diffConfig) withDefaults() diffConfig {
+	if c.Budget <= 0 {
+		c.Budget = 8000
+	}
+	if c.Chunk <= 0 || c.Chunk > c.Budget {
+		c.Chunk = min(3000, c.Budget)
+	}
+	c.Exclude = append(append([]string{}, defaultDiffExclude...), c.Exclude...)
+	return c
+}
+
+// estimateTokens is a rough count, about four bytes per token for code.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// fileDiff is the diff of one file: its "diff --git" header and hunks.
+type fileDiff struct {
+	path      string
+	header    string
+	hunks     []string
+	added     int
+	deleted   int
+	binary    bool
+	generated bool
+}
+
+func (f fileDiff) String() string {
+	return f.header + strings.Join(f.hunks, "")
+}
+
+// splitDiff splits a git diff per file and per hunk.
+func splitDiff(diff string) []fileDiff {
+	var (
+		files []fileDiff
+		cur   *fileDiff
+		hunk  strings.Builder
+	)
+	flushHunk := func() {
+		if cur != nil && hunk.Len() > 0 {
+			cur.hunks = append(cur.hunks, hunk.String())
+		}
+		hunk.Reset()
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushHunk()
+			files = append(files, fileDiff{path: diffPath(line)})
+			cur = &files[len(files)-1]
+			cur.header = line
+		case cur == nil:
+			// leading garbage, not part of a file
+		case len(cur.hunks) == 0 && hunk.Len() == 0 && !strings.HasPrefix(line, "@@"):
+			cur.header += line
+			if strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch") {
+				cur.binary = true
+			}
+			if p, ok := strings.CutPrefix(line, "+++ b/"); ok {
+				cur.path = strings.TrimSuffix(p, "\n")
+			}
+		default:
+			if strings.HasPrefix(line, "@@") {
+				flushHunk()
+			}
+			hunk.WriteString(line)
+			switch {
+			case strings.HasPrefix(line, "+"):
+				cur.added++
+				if strings.Contains(line, "Code generated") && strings.Contains(line, "DO NOT EDIT") {
+					cur.generated = true
+				}
+			case strings.HasPrefix(line, "-"):
+				cur.deleted++
+			}
+		}
+	}
+	flushHunk()
+	return files
+}
+
+// diffPath reads b/<path> in a "diff --git a/<path> b/<path>" line.
+func diffPath(line string) string {
+	line = strings.TrimSuffix(line, "\n")
+	if i := strings.LastIndex(line, " b/"); i >= 0 {
+		return line[i+len(" b/"):]
+	}
+	return strings.TrimPrefix(line, "diff --git ")
+}
+
+// matchGlob matches a slash separated path: patterns without a slash match
+// the base name, "**/" matches any leading directories and "/**" any
+// trailing path.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	if rest, ok := strings.CutPrefix(pattern, "**/"); ok {
+		parts := strings.Split(name, "/")
+		for i := range parts {
+			if matchGlob(rest, strings.Join(parts[i:], "/")) {
+				return true
+			}
+		}
+		return false
+	}
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		parts := strings.Split(name, "/")
+		for i := 1; i < len(parts); i++ {
+			if ok, _ := path.Match(dir, strings.Join(parts[:i], "/")); ok {
+				return true
+			}
+		}
+		return false
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// diffPlan is what is sent to the model: chunks of diff and one line notes
+// for the files left out.
+type diffPlan struct {
+	chunks []string
+	notes  []string
+}
+
+func (p diffPlan) diff() string {
+	return strings.Join(p.chunks, "")
+}
+
+// plan filters noisy files and splits the diff in chunks when it does not
+// fit in the budget.
+func (c diffConfig) plan(diff string) diffPlan {
+	c = c.withDefaults()
+	var (
+		p      diffPlan
+		whole  []string // file diffs
+		pieces []string // file diffs, or hunks with their file header
+		total  int
+	)
+	for _, f := range splitDiff(diff) {
+		if note := c.noisy(f); note != "" {
+			p.notes = append(p.notes, fmt.Sprintf("%s: %s (+%d -%d)", f.path, note, f.added, f.deleted))
+			continue
+		}
+		s := f.String()
+		whole = append(whole, s)
+		total += estimateTokens(s)
+		if estimateTokens(s) <= c.Chunk {
+			pieces = append(pieces, s)
+			continue
+		}
+		for _, h := range f.hunks {
+			piece := f.header + h
+			if estimateTokens(piece) > c.Chunk {
+				piece = strings.ToValidUTF8(piece[:c.Chunk*4], "") + "\n[... hunk truncated ...]\n"
+			}
+			pieces = append(pieces, piece)
+		}
+	}
+	if total <= c.Budget {
+		if len(whole) > 0 {
+			p.chunks = []string{strings.Join(whole, "")}
+		}
+		return p
+	}
+	var chunk strings.Builder
+	for _, piece := range pieces {
+		if chunk.Len() > 0 && estimateTokens(chunk.String()+piece) > c.Chunk {
+			p.chunks = append(p.chunks, chunk.String())
+			chunk.Reset()
+		}
+		chunk.WriteString(piece)
+	}
+	if chunk.Len() > 0 {
+		p.chunks = append(p.chunks, chunk.String())
+	}
+	return p
+}
+
+// noisy tells why a file diff is left out of the prompt, if it is.
+func (c diffConfig) noisy(f fileDiff) string {
+	switch {
+	case f.binary:
+		return "binary file"
+	case f.generated:
+		return "generated file"
+	}
+	for _, pattern := range c.Exclude {
+		if matchGlob(pattern, f.path) {
+			return "excluded by " + pattern
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func fakeDiff(path string, hunks int, linesPerHunk int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%[1]s b/%[1]s\nindex 1111111..2222222 100644\n--- a/%[1]s\n+++ b/%[1]s\n", path)
+	for h := 0; h < hunks; h++ {
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@ func f%d() {\n", h*100+1, linesPerHunk, h*100+1, linesPerHunk, h)
+		for l := 0; l < linesPerHunk; l++ {
+			fmt.Fprintf(&b, "+\tline %d of hunk %d in %s\n", l, h, path)
+		}
+	}
+	return b.String()
+}
+
+func Test_splitDiff(t *testing.T) {
+	diff := fakeDiff("cmd/root.go", 2, 3) +
+		"diff --git a/logo.png b/logo.png\nindex 1111111..2222222 100644\nBinary files a/logo.png and b/logo.png differ\n" +
+		"diff --git a/api.go b/api.go\nnew file mode 100644\n--- /dev/null\n+++ b/api.go\n@@ -0,0 +1,2 @@\n+// Code generated by stringer. DO NOT EDIT.\n+package api\n"
+	files := splitDiff(diff)
+	if len(files) != 3 {
+		t.Fatalf("got %d files", len(files))
+	}
+	if f := files[0]; f.path != "cmd/root.go" || len(f.hunks) != 2 || f.added != 6 || f.String() != fakeDiff("cmd/root.go", 2, 3) {
+		t.Errorf("files[0] = %+v", f)
+	}
+	if !files[1].binary || files[1].path != "logo.png" {
+		t.Errorf("files[1] = %+v", files[1])
+	}
+	if !files[2].generated || files[2].path != "api.go" {
+		t.Errorf("files[2] = %+v", files[2])
+	}
+}
+
+func Test_matchGlob(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"go.sum", "go.sum", true},
+		{"go.sum", "tools/go.sum", true},
+		{"*.lock", "web/yarn.lock", true},
+		{"**/vendor/**", "vendor/github.com/x/y.go", true},
+		{"**/vendor/**", "a/vendor/y.go", true},
+		{"**/vendor/**", "vendors.go", false},
+		{"gen/**", "gen/a/b.go", true},
+		{"gen/**", "src/gen/b.go", false},
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+	} {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v", tt.pattern, tt.name, got)
+		}
+	}
+}
+
+func Test_diffConfig_plan(t *testing.T) {
+	small := fakeDiff("a.go", 1, 3) + fakeDiff("go.sum", 1, 50)
+	p := diffConfig{}.plan(small)
+	if len(p.chunks) != 1 || strings.Contains(p.chunks[0], "go.sum") {
+		t.Errorf("chunks = %q", p.chunks)
+	}
+	if len(p.notes) != 1 || !strings.HasPrefix(p.notes[0], "go.sum: excluded by go.sum (+50 -0)") {
+		t.Errorf("notes = %q", p.notes)
+	}
+
+	big := fakeDiff("a.go", 4, 40) + fakeDiff("b.go", 1, 10) + fakeDiff("schema.sql", 1, 10)
+	c := diffConfig{Budget: 1000, Chunk: 600, Exclude: []string{"*.sql"}}
+	p = c.plan(big)
+	if len(p.chunks) < 3 {
+		t.Fatalf("got %d chunks", len(p.chunks))
+	}
+	for i, chunk := range p.chunks {
+		if n := estimateTokens(chunk); n > 600 {
+			t.Errorf("chunk %d is %d tokens", i, n)
+		}
+		if !strings.HasPrefix(chunk, "diff --git ") {
+			t.Errorf("chunk %d does not start with a file header", i)
+		}
+	}
+	if len(p.notes) != 1 || !strings.HasPrefix(p.notes[0], "schema.sql") {
+		t.Errorf("notes = %q", p.notes)
+	}
+}
+
+// recordGenerator answers with the number of the call.
+type recordGenerator struct{ prompts []string }
+
+func (g *recordGenerator) Generate(ctx context.Context, req genRequest) (string, error) {
+	g.prompts = append(g.prompts, req.prompt)
+	return fmt.Sprintf("answer %d", len(g.prompts)), nil
+}
+
+func Test_commitFlow_mapReduce(t *testing.T) {
+	gen := &recordGenerator{}
+	var out strings.Builder
+	flow := commitFlow{
+		gen:    gen,
+		out:    &out,
+		budget: diffConfig{Budget: 1000, Chunk: 600},
+		debug:  zap.NewNop(),
+	}
+	msg, err := flow.generate(context.Background(), fakeDiff("a.go", 4, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(gen.prompts)
+	if n < 3 || msg != fmt.Sprintf("answer %d", n) {
+		t.Fatalf("%d prompts, msg %q", n, msg)
+	}
+	last := gen.prompts[n-1]
+	for i := 1; i < n; i++ {
+		if !strings.Contains(last, fmt.Sprintf("answer %d", i)) {
+			t.Errorf("final prompt misses summary %d:\n%s", i, last)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// draftsConfig is the "drafts" section of the configuration.
+type draftsConfig struct {
+	Keep int `yaml:"keep"` // drafts kept, the oldest are removed, 0 keeps them all
+}
+
+// draftLayout names the draft files, sortable and valid on every file
+// system.
+const draftLayout = "2006-01-02T15-04-05.000000"
+
+// draftStore keeps the generated commit messages in $GIT_DIR/yag/drafts,
+// out of the working tree.
+type draftStore struct {
+	dir  string
+	keep int
+}
+
+type draft struct {
+	name string
+	path string
+	time time.Time
+	msg  string
+}
+
+// subject is the first line of the message.
+func (d draft) subject() string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(d.msg), "\n")
+	return subject
+}
+
+func openDrafts(repo Repo, cfg draftsConfig) (draftStore, error) {
+	gitDir, err := repo.GitDir()
+	if err != nil {
+		return draftStore{}, err
+	}
+	return draftStore{dir: filepath.Join(gitDir, "yag", "drafts"), keep: cfg.Keep}, nil
+}
+
+// save writes a new draft, then removes the drafts over the limit.
+func (s draftStore) save(msg string) (draft, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return draft{}, err
+	}
+	now := time.Now().UTC()
+	var d draft
+	for {
+		d = draft{name: now.Format(draftLayout) + ".txt", time: now, msg: msg}
+		d.path = filepath.Join(s.dir, d.name)
+		f, err := os.OpenFile(d.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			now = now.Add(time.Microsecond) // several drafts saved at once
+			continue
+		}
+		if err != nil {
+			return d, err
+		}
+		_, err = f.WriteString(msg)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return d, err
+		}
+		break
+	}
+	return d, s.prune()
+}
+
+// list returns the drafts, the most recent first.
+func (s draftStore) list() ([]draft, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var drafts []draft
+	for _, e := range entries {
+		t, err := time.Parse(draftLayout, strings.TrimSuffix(e.Name(), ".txt"))
+		if err != nil || e.IsDir() {
+			continue // not a draft
+		}
+		d := draft{name: e.Name(), path: filepath.Join(s.dir, e.Name()), time: t}
+		b, err := os.ReadFile(d.path)
+		if err != nil {
+			return nil, err
+		}
+		d.msg = string(b)
+		drafts = append(drafts, d)
+	}
+	sort.Slice(drafts, func(i, j int) bool { return drafts[i].time.After(drafts[j].time) })
+	return drafts, nil
+}
+
+// find returns a draft by file name or by number in the list, the most
+// recent one when id is empty.
+func (s draftStore) find(id string) (draft, error) {
+	drafts, err := s.list()
+	if err != nil {
+		return draft{}, err
+	}
+	if len(drafts) == 0 {
+		return draft{}, errors.New("no draft")
+	}
+	if id == "" {
+		return drafts[0], nil
+	}
+	if n, err := strconv.Atoi(id); err == nil {
+		if n < 1 || n > len(drafts) {
+			return draft{}, fmt.Errorf("no draft %d, there are %d", n, len(drafts))
+		}
+		return drafts[n-1], nil
+	}
+	for _, d := range drafts {
+		if d.name == id || strings.TrimSuffix(d.name, ".txt") == id {
+			return d, nil
+		}
+	}
+	return draft{}, fmt.Errorf("no draft %q", id)
+}
+
+func (s draftStore) remove(d draft) error {
+	return os.Remove(d.path)
+}
+
+// prune removes the oldest drafts over the retention limit.
+func (s draftStore) prune() error {
+	if s.keep <= 0 {
+		return nil
+	}
+	drafts, err := s.list()
+	if err != nil {
+		return err
+	}
+	for len(drafts) > s.keep {
+		if err = s.remove(drafts[len(drafts)-1]); err != nil {
+			return err
+		}
+		drafts = drafts[:len(drafts)-1]
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newDraftsCommand(repo Repo, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drafts",
+		Short: "list and reuse the generated commit messages kept in $GIT_DIR/yag/drafts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		newDraftsListCommand(repo, out),
+		newDraftsShowCommand(repo, out),
+		newDraftsUseCommand(repo),
+		newDraftsRmCommand(repo),
+	)
+	return cmd
+}
+
+// draftsFor opens the draft store with the configured retention.
+func draftsFor(repo Repo) (draftStore, error) {
+	cfg, err := loadConfig(repo, nil)
+	if err != nil {
+		return draftStore{}, err
+	}
+	return openDrafts(repo, cfg.Drafts)
+}
+
+func newDraftsListCommand(repo Repo, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list the drafts, the most recent first",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := draftsFor(repo)
+			if err != nil {
+				return err
+			}
+			drafts, err := store.list()
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			for i, d := range drafts {
+				fmt.Fprintf(tw, "%d\t%s\t%s\n", i+1, d.time.Local().Format("2006-01-02 15:04:05"), d.subject())
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func newDraftsShowCommand(repo Repo, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show [draft]",
+		Short: "print a draft by number or file name, the most recent by default",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := draftsFor(repo)
+			if err != nil {
+				return err
+			}
+			d, err := store.find(firstArg(args))
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprint(out, d.msg)
+			return err
+		},
+	}
+}
+
+func newDraftsUseCommand(repo Repo) *cobra.Command {
+	return &cobra.Command{
+		Use:   "use [draft]",
+		Short: "commit with a draft, the most recent by default, then remove it",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := draftsFor(repo)
+			if err != nil {
+				return err
+			}
+			d, err := store.find(firstArg(args))
+			if err != nil {
+				return err
+			}
+			if err = repo.Commit(commitOpts{file: d.path, cleanup: "strip"}); err != nil {
+				return err
+			}
+			return store.remove(d)
+		},
+	}
+}
+
+func newDraftsRmCommand(repo Repo) *cobra.Command {
+	var allOpt *bool
+	cmd := &cobra.Command{
+		Use:   "rm draft...",
+		Short: "remove drafts by number or file name",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := draftsFor(repo)
+			if err != nil {
+				return err
+			}
+			switch {
+			case len(args) == 0 && !*allOpt:
+				return errors.New("no draft given, use --all to remove them all")
+			case len(args) > 0 && *allOpt:
+				return errors.New("--all removes all the drafts, give no draft with it")
+			}
+			var drafts []draft
+			if *allOpt {
+				drafts, err = store.list()
+				if err != nil {
+					return err
+				}
+			}
+			// find all before removing, numbers shift
+			for _, id := range args {
+				d, err := store.find(id)
+				if err != nil {
+					return err
+				}
+				if !slices.ContainsFunc(drafts, func(o draft) bool { return o.path == d.path }) {
+					drafts = append(drafts, d)
+				}
+			}
+			for _, d := range drafts {
+				if err = store.remove(d); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	allOpt = cmd.Flags().Bool("all", false, "remove all the drafts")
+	return cmd
+}
+
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_draftStore(t *testing.T) {
+	store := draftStore{dir: t.TempDir(), keep: 2}
+	for _, msg := range []string{"first\n", "second\n\nbody\n", "third\n"} {
+		if _, err := store.save(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(store.dir+"/notes.txt", []byte("not a draft"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	drafts, err := store.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for _, d := range drafts {
+		subjects = append(subjects, d.subject())
+	}
+	if want := []string{"third", "second"}; !slices.Equal(subjects, want) {
+		t.Errorf("subjects = %q, want %q", subjects, want)
+	}
+	for id, want := range map[string]string{"": "third", "2": "second", drafts[1].name: "second"} {
+		d, err := store.find(id)
+		if err != nil || d.subject() != want {
+			t.Errorf("find(%q) = %q, %v, want %q", id, d.subject(), err, want)
+		}
+	}
+	if _, err = store.find("3"); err == nil {
+		t.Error("find(3) found a removed draft")
+	}
+}
+
+func Test_newDraftsCommand(t *testing.T) {
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	store, err := openDrafts(repo, draftsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"older\n", "newer\n\n# comment\n"} {
+		if _, err = store.save(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		cmd := newDraftsCommand(repo, &out)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	list := run("list")
+	if !strings.Contains(list, "1  ") || strings.Index(list, "newer") > strings.Index(list, "older") {
+		t.Errorf("list:\n%s", list)
+	}
+	if got := run("show", "2"); got != "older\n" {
+		t.Errorf("show 2 = %q", got)
+	}
+	run("use")
+	if last := repo.calls[len(repo.calls)-1]; !strings.HasPrefix(last, "commit --file "+store.dir) || !strings.HasSuffix(last, "--cleanup=strip") {
+		t.Errorf("calls = %q", repo.calls)
+	}
+	if !slices.Equal(repo.commits, []string{"newer\n\n# comment\n"}) {
+		t.Errorf("commits = %q", repo.commits)
+	}
+	if _, err = store.save("again\n"); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"rm", "--all", "1"}, {"rm", "3"}} {
+		cmd := newDraftsCommand(repo, &bytes.Buffer{})
+		cmd.SetArgs(args)
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("%q: no error", args)
+		}
+	}
+	if drafts, _ := store.list(); len(drafts) != 2 {
+		t.Errorf("drafts removed on error: %v", drafts)
+	}
+	run("rm", "2", "2")
+	if drafts, _ := store.list(); len(drafts) != 1 {
+		t.Errorf("drafts left after rm 2 2: %v", drafts)
+	}
+	run("rm", "--all")
+	if drafts, _ := store.list(); len(drafts) != 0 {
+		t.Errorf("drafts left: %v", drafts)
+	}
+}
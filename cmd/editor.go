@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// errEmptyMessage aborts a commit like git does.
+var errEmptyMessage = errors.New("aborting commit due to empty commit message")
+
+// editor is the user's editor as a shell command, run the way git runs it
+// so that "code --wait" or "emacsclient -t" work.
+type editor struct {
+	command     string
+	commentChar string
+}
+
+// resolveEditor finds the editor with the precedence of git var
+// GIT_EDITOR: $GIT_EDITOR, core.editor, $VISUAL unless the terminal is
+// dumb, $EDITOR, then vi. The yag editor key, when set, comes in place of
+// core.editor: $GIT_EDITOR still wins. Like git, which never runs an editor
+// of a tracked file, the key is not read from the repository file.
+func resolveEditor(repo Repo, configured string, getenv func(string) string) (editor, error) {
+	commentChar, err := repo.Config("core.commentChar")
+	if err != nil {
+		return editor{}, err
+	}
+	e := editor{commentChar: commentChar}
+	if e.commentChar == "" {
+		e.commentChar = "#"
+	}
+	coreEditor, err := repo.Config("core.editor")
+	if err != nil {
+		return e, err
+	}
+	dumb := getenv("TERM") == "dumb"
+	for _, c := range []string{getenv("GIT_EDITOR"), configured, coreEditor} {
+		if c != "" {
+			e.command = c
+			return e, nil
+		}
+	}
+	if v := getenv("VISUAL"); v != "" && !dumb {
+		e.command = v
+		return e, nil
+	}
+	if v := getenv("EDITOR"); v != "" {
+		e.command = v
+		return e, nil
+	}
+	if dumb {
+		return e, errors.New("terminal is dumb, but EDITOR unset")
+	}
+	e.command = "vi"
+	return e, nil
+}
+
+// comment returns the comment character of a message, picking one not
+// starting any of its lines when core.commentChar is auto.
+func (e editor) comment(msg string) string {
+	if e.commentChar != "auto" {
+		return e.commentChar
+	}
+	for _, c := range "#;@!$%^&|:" {
+		used := false
+		for _, line := range strings.Split(msg, "\n") {
+			if strings.HasPrefix(line, string(c)) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			return string(c)
+		}
+	}
+	return "#"
+}
+
+// edit adds the usual instructions to the message file, opens it and
+// returns the cleaned up message, errEmptyMessage when nothing is left.
+func (e editor) edit(name string) (string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	msg := string(b)
+	c := e.comment(msg)
+	hint := fmt.Sprintf("\n%[1]s Please enter the commit message for your changes. Lines starting\n"+
+		"%[1]s with '%[1]s' will be ignored, and an empty message aborts the commit.\n", c)
+	if !strings.Contains(msg, hint) {
+		msg = strings.TrimRight(msg, "\n") + "\n" + hint
+		if err = os.WriteFile(name, []byte(msg), 0644); err != nil {
+			return "", err
+		}
+	}
+	x := exec.Command("sh", "-c", e.command+` "$@"`, e.command, name)
+	x.Stdin = os.Stdin
+	x.Stdout = os.Stdout
+	x.Stderr = os.Stderr
+	if err = x.Run(); err != nil {
+		return "", fmt.Errorf("there was a problem with the editor %q: %w", e.command, err)
+	}
+	b, err = os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	msg = cleanupMessage(string(b), c)
+	if msg == "" {
+		return "", errEmptyMessage
+	}
+	return msg, nil
+}
+
+// cleanupMessage is git commit --cleanup=strip: the verbose diff, comment
+// lines and trailing whitespace go, blank lines are collapsed and trimmed.
+func cleanupMessage(msg, commentChar string) string {
+	if i := strings.Index(msg, commentChar+scissors[1:]); i >= 0 {
+		msg = msg[:i]
+	}
+	var lines []string
+	for _, line := range strings.Split(msg, "\n") {
+		if commentChar != "" && strings.HasPrefix(line, commentChar) {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func Test_resolveEditor(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		gitConfig  map[string]string
+		env        map[string]string
+		want       string
+		wantErr    bool
+	}{
+		{"default", "", nil, nil, "vi", false},
+		{"yag config over core.editor", "nano", map[string]string{"core.editor": "vim"}, map[string]string{"VISUAL": "x"}, "nano", false},
+		{"GIT_EDITOR over yag config", "nano", map[string]string{"core.editor": "vim"}, map[string]string{"GIT_EDITOR": "ed"}, "ed", false},
+		{"GIT_EDITOR", "", map[string]string{"core.editor": "vim"}, map[string]string{"GIT_EDITOR": "code --wait", "VISUAL": "x"}, "code --wait", false},
+		{"core.editor", "", map[string]string{"core.editor": "emacsclient -t"}, map[string]string{"VISUAL": "x", "EDITOR": "y"}, "emacsclient -t", false},
+		{"VISUAL", "", nil, map[string]string{"VISUAL": "x", "EDITOR": "y"}, "x", false},
+		{"dumb terminal skips VISUAL", "", nil, map[string]string{"VISUAL": "x", "EDITOR": "y", "TERM": "dumb"}, "y", false},
+		{"dumb terminal without EDITOR", "", nil, map[string]string{"TERM": "dumb"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			repo.config = tt.gitConfig
+			got, err := resolveEditor(repo, tt.configured, func(k string) string { return tt.env[k] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.command != tt.want {
+				t.Errorf("editor = %q, want %q", got.command, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resolveEditor_repoConfig(t *testing.T) {
+	repo := newFakeRepo()
+	configFiles(t, repo, "", "editor: touch pwned\n")
+	restore := warn
+	defer func() { warn = restore }()
+	warn = func(string, ...any) {}
+	cfg, err := loadConfig(repo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := resolveEditor(repo, cfg.Editor, func(string) string { return "" })
+	if err != nil || got.command != "vi" {
+		t.Errorf("editor = %q, %v, want vi", got.command, err)
+	}
+}
+
+func Test_cleanupMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         string
+		commentChar string
+		want        string
+	}{
+		{"comments", "subject\n# comment\n\nbody  \n", "#", "subject\n\nbody\n"},
+		{"blank lines", "\n\nsubject\n\n\n\nbody\n\n", "#", "subject\n\nbody\n"},
+		{"comment char", "subject\n; comment\n#1 is kept\n", ";", "subject\n#1 is kept\n"},
+		{"verbose diff", "subject\n" + scissors + "\ndiff --git a/x b/x\n", "#", "subject\n"},
+		{"empty", "# only comments\n\n", "#", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanupMessage(tt.msg, tt.commentChar); got != tt.want {
+				t.Errorf("cleanupMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_editor_comment_auto(t *testing.T) {
+	e := editor{commentChar: "auto"}
+	if got := e.comment("#1 fixed\n; not a comment\n"); got != "@" {
+		t.Errorf("comment() = %q, want @", got)
+	}
+}
+
+// fakeEditor writes a script appending its first argument to the edited
+// file, to check editors with arguments.
+func fakeEditor(t *testing.T) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "editor")
+	if err := os.WriteFile(name, []byte("#!/bin/sh\nprintf '%s\\n' \"$1\" >> \"$2\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func Test_editor_edit(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "msg")
+	if err := os.WriteFile(name, []byte("subject\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := editor{command: fakeEditor(t) + " 'Refs: #12'", commentChar: ";"}
+	got, err := e.edit(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "subject\n\nRefs: #12\n"; got != want {
+		t.Errorf("edit() = %q, want %q", got, want)
+	}
+
+	if err = os.WriteFile(name, []byte("\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (editor{command: "true", commentChar: "#"}).edit(name); !errors.Is(err, errEmptyMessage) {
+		t.Errorf("error = %v, want %v", err, errEmptyMessage)
+	}
+}
+
+func Test_commitFlow_commit(t *testing.T) {
+	t.Setenv("GIT_EDITOR", "")
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	repo.config = map[string]string{"core.editor": fakeEditor(t) + " 'Signed-off-by: A <a@b>'"}
+	drafts, err := openDrafts(repo, draftsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := drafts.save("cmd.dev-202501021504.05\n\nadd repo\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow := commitFlow{repo: repo, out: &bytes.Buffer{}, drafts: drafts, debug: zap.NewNop()}
+	if err = flow.commit(d); err != nil {
+		t.Fatal(err)
+	}
+	want := "cmd.dev-202501021504.05\n\nadd repo\n\nSigned-off-by: A <a@b>\n"
+	if len(repo.commits) != 1 || repo.commits[0] != want {
+		t.Errorf("commits = %q, want %q", repo.commits, want)
+	}
+	if left, _ := drafts.list(); len(left) != 0 {
+		t.Errorf("draft not removed after the commit: %v", left)
+	}
+}
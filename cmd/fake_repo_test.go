@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// fakeRepo is an in-memory Repo: Add and RestoreStaged move files between
+// the worktree and the index, and every call is recorded.
+type fakeRepo struct {
+	status  repoStatus
+	diff    string
+	root    string
+	log     []string // commit subjects, most recent last
+	tags    []string
+	pushed  []string
+	calls   []string
+	commits []string          // messages of the commits
+	config  map[string]string // git configuration
+}
+
+func (r *fakeRepo) record(name string, args ...string) {
+	r.calls = append(r.calls, strings.TrimSpace(name+" "+strings.Join(args, " ")))
+}
+
+func (r *fakeRepo) Status() (repoStatus, error) {
+	r.record("status")
+	s := r.status
+	s.files = slices.Clone(r.status.files)
+	return s, nil
+}
+
+func (r *fakeRepo) PrintStatus(args ...string) error {
+	r.record("print-status", args...)
+	return nil
+}
+
+func (r *fakeRepo) DiffCached() (string, error) {
+	r.record("diff-cached")
+	return r.diff, nil
+}
+
+func (r *fakeRepo) find(path string) (int, error) {
+	for i, f := range r.status.files {
+		if f.path == path {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("pathspec %q did not match any files", path)
+}
+
+func (r *fakeRepo) Add(paths ...string) error {
+	r.record("add", paths...)
+	for _, p := range paths {
+		i, err := r.find(p)
+		if err != nil {
+			return err
+		}
+		f := &r.status.files[i]
+		switch {
+		case f.untracked():
+			*f = fstat{kind: statusOrdinary, staged: 'A', unstaged: '.', path: f.path}
+		case f.unstaged != '.':
+			f.staged, f.unstaged = f.unstaged, '.'
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) RestoreStaged(paths ...string) error {
+	r.record("restore-staged", paths...)
+	for _, p := range paths {
+		i, err := r.find(p)
+		if err != nil {
+			return err
+		}
+		f := &r.status.files[i]
+		switch {
+		case f.untrackedNewFile():
+			*f = fstat{kind: statusUntracked, staged: '?', unstaged: '?', path: f.path}
+		case f.isStaged():
+			f.staged, f.unstaged = '.', f.staged
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) Commit(opts commitOpts) error {
+	r.record("commit", opts.args()[1:]...)
+	var msg string
+	switch {
+	case opts.message != nil:
+		b, err := io.ReadAll(opts.message)
+		if err != nil {
+			return err
+		}
+		msg = string(b)
+	case opts.file != "" && opts.file != "-":
+		b, err := os.ReadFile(opts.file)
+		if err != nil {
+			return err
+		}
+		msg = string(b)
+	}
+	if !opts.amend {
+		var rest []fstat
+		staged := 0
+		for _, f := range r.status.files {
+			if f.isStaged() {
+				staged++
+			}
+			switch {
+			case !f.isStaged():
+				rest = append(rest, f)
+			case f.unstaged != '.':
+				f.staged = '.'
+				rest = append(rest, f)
+			}
+		}
+		if staged == 0 && !opts.allowEmpty {
+			return fmt.Errorf("nothing to commit")
+		}
+		r.status.files = rest
+		r.diff = ""
+	}
+	r.commits = append(r.commits, msg)
+	subject, _, _ := strings.Cut(msg, "\n")
+	r.log = append(r.log, subject)
+	return nil
+}
+
+func (r *fakeRepo) Tag(name string) error {
+	r.record("tag", name)
+	r.tags = append(r.tags, name)
+	return nil
+}
+
+func (r *fakeRepo) Tags() ([]string, error) {
+	r.record("tags")
+	return slices.Clone(r.tags), nil
+}
+
+func (r *fakeRepo) Push(remote string, refs ...string) error {
+	r.record("push", append([]string{remote}, refs...)...)
+	r.pushed = append(r.pushed, refs...)
+	return nil
+}
+
+func (r *fakeRepo) LogOne() (string, error) {
+	r.record("log-one")
+	if len(r.log) == 0 {
+		return "", fmt.Errorf("your current branch does not have any commits yet")
+	}
+	return "0000000 " + r.log[len(r.log)-1], nil
+}
+
+func (r *fakeRepo) LastMessage() (string, error) {
+	r.record("last-message")
+	if len(r.commits) == 0 {
+		if len(r.log) == 0 {
+			return "", fmt.Errorf("your current branch does not have any commits yet")
+		}
+		return r.log[len(r.log)-1] + "\n", nil
+	}
+	return r.commits[len(r.commits)-1], nil
+}
+
+func (r *fakeRepo) Subjects(n int) ([]string, error) {
+	r.record("subjects", strconv.Itoa(n))
+	var subjects []string
+	for i := len(r.log) - 1; i >= 0 && len(subjects) < n; i-- {
+		subjects = append(subjects, r.log[i])
+	}
+	return subjects, nil
+}
+
+func (r *fakeRepo) GitDir() (string, error) {
+	r.record("git-dir")
+	return filepath.Join(r.root, ".git"), nil
+}
+
+func (r *fakeRepo) Config(key string) (string, error) {
+	r.record("config", key)
+	return r.config[key], nil
+}
+
+func (r *fakeRepo) Root() (string, error) {
+	r.record("root")
+	return r.root, nil
+}
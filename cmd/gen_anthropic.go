@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
+)
+
+// anthropicGenerator calls the Anthropic Messages API directly with an API
+// key, for those without a Vertex AI project.
+type anthropicGenerator struct {
+	baseURL string
+	apiKey  string
+	version string // anthropic-version header
+	model   string
+	deps    genDeps
+	debug   *zap.Logger
+}
+
+// anthropicAPIKey is the configured key, anthropic.api_key or
+// YAG_ANTHROPIC_API_KEY, or the one of the environment.
+func anthropicAPIKey(cfg anthropicConfig) string {
+	if cfg.APIKey != "" {
+		return cfg.APIKey
+	}
+	return os.Getenv("ANTHROPIC_API_KEY")
+}
+
+// anthropicBaseURLOf is the configured base url, or the one of the
+// environment, or the public api. The key goes there: anthropic.base_url
+// is not read from the repository file, --base-url still wins.
+func anthropicBaseURLOf(cfg anthropicConfig) string {
+	for _, u := range []string{cfg.BaseURL, os.Getenv("ANTHROPIC_BASE_URL")} {
+		if u != "" {
+			return strings.TrimSuffix(u, "/")
+		}
+	}
+	return anthropicBaseURL
+}
+
+func (g anthropicGenerator) Generate(ctx context.Context, req genRequest) (string, error) {
+	if g.apiKey == "" {
+		return "", fmt.Errorf("anthropic: no api key, set anthropic.api_key or ANTHROPIC_API_KEY")
+	}
+	version := g.version
+	if version == "" {
+		version = anthropicVersion
+	}
+	payload := newClaudeRequest(req)
+	payload.Model = g.model
+	header := make(http.Header)
+	header.Add("x-api-key", g.apiKey)
+	header.Add("anthropic-version", version)
+	g.debug.Debug("new request for anthropic api",
+		zap.String("base_url", g.baseURL),
+		zap.String("anthropic_version", version),
+		zap.Int("max_tokens", payload.MaxTokens),
+		zap.String("model", g.model),
+	)
+	text, err := postClaude(ctx, g.deps.httpClient(), g.baseURL+"/v1/messages", header, payload, req.stream, g.debug)
+	if err != nil {
+		return "", fmt.Errorf("anthropic: %w", err)
+	}
+	return text, nil
+}
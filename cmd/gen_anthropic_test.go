@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// anthropicStub checks the request shape then answers with status and body.
+func anthropicStub(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/messages" {
+			t.Errorf("%s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "sk-test" {
+			t.Errorf("x-api-key = %q", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got != anthropicVersion {
+			t.Errorf("anthropic-version = %q", got)
+		}
+		var req struct {
+			Version   *string          `json:"anthropic_version"`
+			Model     string           `json:"model"`
+			MaxTokens int              `json:"max_tokens"`
+			Messages  []map[string]any `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Version != nil || req.Model != "claude-test" || req.MaxTokens != 256 || len(req.Messages) != 1 {
+			t.Errorf("request = %+v", req)
+		}
+		w.Header().Set("request-id", "req_123")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func Test_anthropicGenerator(t *testing.T) {
+	srv := anthropicStub(t, http.StatusOK, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Add anthropic backend"}],"stop_reason":"end_turn"}`)
+	defer srv.Close()
+
+	g := anthropicGenerator{baseURL: srv.URL, apiKey: "sk-test", model: "claude-test", debug: zap.NewNop()}
+	got, err := g.Generate(context.Background(), genRequest{prompt: "diff", maxTokens: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Add anthropic backend" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func Test_anthropicGenerator_apiError(t *testing.T) {
+	srv := anthropicStub(t, http.StatusUnauthorized, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+	defer srv.Close()
+
+	g := anthropicGenerator{baseURL: srv.URL, apiKey: "sk-test", model: "claude-test", debug: zap.NewNop()}
+	_, err := g.Generate(context.Background(), genRequest{prompt: "diff", maxTokens: 256})
+	var apiErr claudeAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want a claudeAPIError", err)
+	}
+	if apiErr.Type != "authentication_error" || apiErr.Message != "invalid x-api-key" || apiErr.requestID != "req_123" {
+		t.Errorf("err = %+v", apiErr)
+	}
+}
+
+func Test_anthropicGenerator_noKey(t *testing.T) {
+	g := anthropicGenerator{baseURL: "http://127.0.0.1:0", model: "claude-test", debug: zap.NewNop()}
+	if _, err := g.Generate(context.Background(), genRequest{prompt: "diff", maxTokens: 256}); err == nil {
+		t.Fatal("expected an error without api key")
+	}
+}
+
+func Test_decodeClaudeError_vertex(t *testing.T) {
+	res := &http.Response{Status: "403 Forbidden", Header: http.Header{}}
+	err := decodeClaudeError(res, []byte(`{"error":{"code":403,"message":"Permission denied","status":"PERMISSION_DENIED"}}`))
+	if got := err.Error(); got != "403 Forbidden: PERMISSION_DENIED: Permission denied" {
+		t.Errorf("got %q", got)
+	}
+	err = decodeClaudeError(res, []byte("upstream connect error\n"))
+	if got := err.Error(); got != "403 Forbidden: upstream connect error" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func Test_claudeResponse_text(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "multiple text blocks",
+			body: `{"model":"m","content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"Subject"},{"type":"text","text":"Body"}],"stop_reason":"end_turn"}`,
+			want: "Subject\n\nBody",
+		},
+		{
+			name: "max_tokens keeps the partial message",
+			body: `{"model":"m","content":[{"type":"text","text":"Subj"}],"stop_reason":"max_tokens","usage":{"output_tokens":256}}`,
+			want: "Subj",
+		},
+		{
+			name:    "empty content",
+			body:    `{"model":"m","content":[],"stop_reason":"end_turn"}`,
+			wantErr: true,
+		},
+		{
+			name:    "tool use only",
+			body:    `{"model":"m","content":[{"type":"tool_use","id":"t","name":"n","input":{}}],"stop_reason":"tool_use"}`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp claudeResponse
+			if err := json.Unmarshal([]byte(tt.body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			got, err := resp.text()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_anthropicGenerator_errorPayload(t *testing.T) {
+	srv := anthropicStub(t, http.StatusOK, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	defer srv.Close()
+
+	g := anthropicGenerator{baseURL: srv.URL, apiKey: "sk-test", model: "claude-test", debug: zap.NewNop()}
+	_, err := g.Generate(context.Background(), genRequest{prompt: "diff", maxTokens: 256})
+	var apiErr claudeAPIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "overloaded_error" {
+		t.Fatalf("err = %v", err)
+	}
+}
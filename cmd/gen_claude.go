@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// claudeRequest is the messages payload shared by Vertex AI and the
+// Anthropic API. Vertex takes the version in the body and the model in the
+// url, the Anthropic API the other way around.
+type claudeRequest struct {
+	Version     string      `json:"anthropic_version,omitempty"`
+	Model       string      `json:"model,omitempty"`
+	System      string      `json:"system,omitempty"`
+	Messges     []claudeMsg `json:"messages"`
+	Stream      bool        `json:"stream"`
+	MaxTokens   int         `json:"max_tokens"`
+	Temperature float64     `json:"temperature,omitempty"`
+}
+
+func newClaudeRequest(req genRequest) claudeRequest {
+	maxTokens := req.maxTokens
+	if maxTokens == 0 {
+		maxTokens = 1024
+	}
+	return claudeRequest{
+		System: req.system,
+		Messges: []claudeMsg{
+			{
+				Role:    "user",
+				Content: []claudeMsgContent{newClaudeMsgTxt(req.prompt)},
+			},
+		},
+		MaxTokens:   maxTokens,
+		Temperature: req.temperature,
+		Stream:      req.stream != nil,
+	}
+}
+
+// claudeResponse is a Messages API response.
+type claudeResponse struct {
+	ID           string               `json:"id"`
+	Type         string               `json:"type"`
+	Role         string               `json:"role"`
+	Model        string               `json:"model"`
+	Content      []claudeContentBlock `json:"content"`
+	StopReason   string               `json:"stop_reason"`
+	StopSequence string               `json:"stop_sequence"`
+	Usage        claudeUsage          `json:"usage"`
+	Error        *claudeAPIError      `json:"error"` // when Type is "error"
+}
+
+type claudeUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// claudeContentBlock is a text, thinking or tool_use block.
+type claudeContentBlock struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	Thinking string          `json:"thinking,omitempty"`
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Input    json.RawMessage `json:"input,omitempty"`
+}
+
+// text concatenates the text blocks of the response. It warns when the
+// message was cut by max_tokens.
+func (r claudeResponse) text() (string, error) {
+	var parts []string
+	for _, b := range r.Content {
+		if b.Type == "text" && b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("%s: empty response (stop_reason %q)", r.Model, r.StopReason)
+	}
+	if r.StopReason == "max_tokens" {
+		warn("the message was truncated after %d tokens (max_tokens)", r.Usage.OutputTokens)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// postClaude sends the payload and returns the text of the response.
+// Streamed responses are written to w as they arrive.
+func postClaude(ctx context.Context, cli *http.Client, url string, header http.Header, payload claudeRequest, w io.Writer, debug *zap.Logger) (string, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header = header
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+	res, err := cli.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	var resp claudeResponse
+	if payload.Stream && res.StatusCode/100 == 2 {
+		resp, err = readClaudeStream(res.Body, w, debug)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil {
+			return "", err
+		}
+		return resp.text()
+	}
+	var out bytes.Buffer
+	if _, err = io.Copy(&out, res.Body); err != nil {
+		return "", err
+	}
+	debug.Debug("claude response returned",
+		zap.String("status", res.Status), zap.String("response", out.String()))
+	if res.StatusCode/100 != 2 {
+		return "", decodeClaudeError(res, out.Bytes())
+	}
+	if err = json.Unmarshal(out.Bytes(), &resp); err != nil {
+		return "", fmt.Errorf("decode %s response: %w", res.Status, err)
+	}
+	if resp.Type == "error" {
+		return "", decodeClaudeError(res, out.Bytes())
+	}
+	debug.Debug("claude usage",
+		zap.String("id", resp.ID),
+		zap.String("stop_reason", resp.StopReason),
+		zap.Int("input_tokens", resp.Usage.InputTokens),
+		zap.Int("output_tokens", resp.Usage.OutputTokens),
+	)
+	return resp.text()
+}
+
+// claudeAPIError is an error body of the Anthropic API, or the Google style
+// error Vertex AI answers with before reaching the model.
+type claudeAPIError struct {
+	status    string
+	requestID string
+	Type      string `json:"type"`
+	Status    string `json:"status"` // Vertex AI
+	Message   string `json:"message"`
+}
+
+func (e claudeAPIError) Error() string {
+	kind := e.Type
+	if kind == "" {
+		kind = e.Status
+	}
+	msg := fmt.Sprintf("%s: %s: %s", e.status, kind, e.Message)
+	if e.requestID != "" {
+		msg += " (request-id " + e.requestID + ")"
+	}
+	return msg
+}
+
+func decodeClaudeError(res *http.Response, body []byte) error {
+	var payload struct {
+		Error claudeAPIError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Error.Message == "" {
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	payload.Error.status = res.Status
+	payload.Error.requestID = res.Header.Get("request-id")
+	return payload.Error
+}
+
+// claudeStreamEvent is the data of a streamed Messages API event.
+type claudeStreamEvent struct {
+	Type         string             `json:"type"`
+	Message      claudeResponse     `json:"message"`       // message_start
+	Index        int                `json:"index"`         // content_block_*
+	ContentBlock claudeContentBlock `json:"content_block"` // content_block_start
+	Delta        struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		Thinking   string `json:"thinking"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage claudeUsage     `json:"usage"` // message_delta
+	Error *claudeAPIError `json:"error"`
+}
+
+// readClaudeStream reads message_start, content_block_delta, ... events up to
+// message_stop and rebuilds the message they describe.
+func readClaudeStream(r io.Reader, w io.Writer, debug *zap.Logger) (claudeResponse, error) {
+	var resp claudeResponse
+	stopped := false
+	block := func(i int) (*claudeContentBlock, error) {
+		if i < 0 || i > len(resp.Content) {
+			return nil, fmt.Errorf("content block %d out of range", i)
+		}
+		if i == len(resp.Content) { // delta without content_block_start
+			resp.Content = append(resp.Content, claudeContentBlock{Type: "text"})
+		}
+		return &resp.Content[i], nil
+	}
+	err := readSSE(r, func(event string, data []byte) error {
+		var ev claudeStreamEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("decode %s event: %w", event, err)
+		}
+		switch ev.Type {
+		case "ping":
+		case "message_start":
+			resp = ev.Message
+		case "content_block_start":
+			resp.Content = append(resp.Content, ev.ContentBlock)
+		case "content_block_delta":
+			b, err := block(ev.Index)
+			if err != nil {
+				return err
+			}
+			switch ev.Delta.Type {
+			case "text_delta":
+				b.Text += ev.Delta.Text
+				if w != nil {
+					if _, err := io.WriteString(w, ev.Delta.Text); err != nil {
+						return err
+					}
+				}
+			case "thinking_delta":
+				b.Thinking += ev.Delta.Thinking
+			}
+		case "content_block_stop":
+		case "message_delta":
+			resp.StopReason = ev.Delta.StopReason
+			resp.Usage.OutputTokens = ev.Usage.OutputTokens
+		case "message_stop":
+			stopped = true
+		case "error":
+			if ev.Error != nil {
+				ev.Error.status = "stream"
+				return *ev.Error
+			}
+			return fmt.Errorf("stream error: %s", data)
+		default:
+			debug.Debug("unknown claude stream event", zap.String("event", event))
+		}
+		return nil
+	})
+	if err != nil {
+		return resp, err
+	}
+	if !stopped {
+		return resp, fmt.Errorf("stream ended before message_stop")
+	}
+	debug.Debug("claude usage",
+		zap.String("id", resp.ID),
+		zap.String("stop_reason", resp.StopReason),
+		zap.Int("input_tokens", resp.Usage.InputTokens),
+		zap.Int("output_tokens", resp.Usage.OutputTokens),
+	)
+	return resp, nil
+}
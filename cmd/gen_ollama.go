@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+
+	ollama "github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
+)
+
+// ollamaGenerator chats with an ollama server, OLLAMA_HOST unless host is
+// set.
+type ollamaGenerator struct {
+	host        string
+	model       string
+	temperature float64 // negative keeps the model default
+	numCtx      int     // zero keeps the model default
+	deps        genDeps
+	debug       *zap.Logger
+}
+
+// ollamaHostURL accepts the same forms as OLLAMA_HOST: host, host:port or
+// scheme://host:port.
+func ollamaHostURL(host string) (*url.URL, error) {
+	noScheme := !strings.Contains(host, "://")
+	if noScheme {
+		host = "http://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("ollama host %q: %w", host, err)
+	}
+	if noScheme && u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "11434")
+	}
+	return u, nil
+}
+
+func (g ollamaGenerator) client() (*ollama.Client, error) {
+	if g.host == "" {
+		return ollama.NewClient(envconfig.Host(), g.deps.httpClient()), nil
+	}
+	u, err := ollamaHostURL(g.host)
+	if err != nil {
+		return nil, err
+	}
+	return ollama.NewClient(u, g.deps.httpClient()), nil
+}
+
+func (g ollamaGenerator) Generate(ctx context.Context, req genRequest) (string, error) {
+	client, err := g.client()
+	if err != nil {
+		return "", err
+	}
+	var messages []ollama.Message
+	if req.system != "" {
+		messages = append(messages, ollama.Message{Role: "system", Content: req.system})
+	}
+	messages = append(messages, ollama.Message{Role: "user", Content: req.prompt})
+	chat := &ollama.ChatRequest{
+		Model:    g.model,
+		Messages: messages,
+		Options:  make(map[string]interface{}),
+	}
+	if req.maxTokens > 0 {
+		chat.Options["num_predict"] = req.maxTokens
+	}
+	if req.temperature > 0 {
+		chat.Options["temperature"] = req.temperature
+	} else if g.temperature >= 0 {
+		chat.Options["temperature"] = g.temperature
+	}
+	if g.numCtx > 0 {
+		chat.Options["num_ctx"] = g.numCtx
+	}
+	var b strings.Builder
+	respFunc := func(resp ollama.ChatResponse) error {
+		b.WriteString(resp.Message.Content)
+		if req.stream != nil {
+			if _, err := fmt.Fprint(req.stream, resp.Message.Content); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	g.debug.Debug("starting ollama chat", zap.String("model", g.model), zap.Any("options", chat.Options))
+	if err = client.Chat(ctx, chat, respFunc); err != nil {
+		return "", fmt.Errorf("ollama %s: %w", g.model, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	ollama "github.com/ollama/ollama/api"
+)
+
+func Test_ollamaGenerator(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		var req ollama.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Model != "llama3.2:3b" {
+			t.Errorf("model = %q", req.Model)
+		}
+		if req.Options["temperature"] != 0.2 || req.Options["num_ctx"] != float64(8192) {
+			t.Errorf("options = %v", req.Options)
+		}
+		if len(req.Messages) != 1 || req.Messages[0].Content != "diff" {
+			t.Errorf("messages = %+v", req.Messages)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, tok := range []string{"Add ", "native ", "ollama"} {
+			fmt.Fprintf(w, `{"model":"llama3.2:3b","message":{"role":"assistant","content":%q},"done":false}`+"\n", tok)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprintln(w, `{"model":"llama3.2:3b","message":{"role":"assistant","content":""},"done":true}`)
+	}))
+	defer srv.Close()
+
+	var stream bytes.Buffer
+	g := ollamaGenerator{
+		host:        srv.URL,
+		model:       "llama3.2:3b",
+		temperature: 0.2,
+		numCtx:      8192,
+		debug:       zap.NewNop(),
+	}
+	got, err := g.Generate(context.Background(), genRequest{prompt: "diff", stream: &stream})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Add native ollama" || stream.String() != got {
+		t.Errorf("got %q, streamed %q", got, stream.String())
+	}
+}
+
+func Test_ollamaGenerator_error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, `{"error":"model \"nope\" not found, try pulling it first"}`)
+	}))
+	defer srv.Close()
+
+	g := ollamaGenerator{host: srv.URL, model: "nope", temperature: -1, debug: zap.NewNop()}
+	if _, err := g.Generate(context.Background(), genRequest{prompt: "diff"}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func Test_ollamaHostURL(t *testing.T) {
+	for host, want := range map[string]string{
+		"localhost":              "http://localhost:11434",
+		"0.0.0.0:8080":           "http://0.0.0.0:8080",
+		"https://ollama.example": "https://ollama.example",
+		"http://127.0.0.1:11434": "http://127.0.0.1:11434",
+	} {
+		u, err := ollamaHostURL(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.String() != want {
+			t.Errorf("ollamaHostURL(%q) = %q, want %q", host, u, want)
+		}
+	}
+}
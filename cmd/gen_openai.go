@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// openaiGenerator calls any OpenAI compatible chat completions endpoint.
+type openaiGenerator struct {
+	baseURL string
+	apiKey  string
+	model   string
+	deps    genDeps
+	debug   *zap.Logger
+}
+
+type openaiMsg struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (g openaiGenerator) Generate(ctx context.Context, req genRequest) (string, error) {
+	payload := struct {
+		Model       string      `json:"model"`
+		Messages    []openaiMsg `json:"messages"`
+		MaxTokens   int         `json:"max_tokens,omitempty"`
+		Temperature float64     `json:"temperature,omitempty"`
+	}{
+		Model:       g.model,
+		MaxTokens:   req.maxTokens,
+		Temperature: req.temperature,
+	}
+	if req.system != "" {
+		payload.Messages = append(payload.Messages, openaiMsg{Role: "system", Content: req.system})
+	}
+	payload.Messages = append(payload.Messages, openaiMsg{Role: "user", Content: req.prompt})
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+		return "", err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+"/chat/completions", &buf)
+	if err != nil {
+		return "", err
+	}
+	hreq.Header.Add("Content-Type", "application/json")
+	if g.apiKey != "" {
+		hreq.Header.Add("Authorization", "Bearer "+g.apiKey)
+	}
+	g.debug.Debug("new request for openai compatible api", zap.String("url", hreq.URL.String()), zap.String("model", g.model))
+	res, err := g.deps.httpClient().Do(hreq)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	var out struct {
+		Choices []struct {
+			Message openaiMsg `json:"message"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("openai: decode %s response: %w", res.Status, err)
+	}
+	if out.Error != nil {
+		return "", fmt.Errorf("openai: %s: %s", res.Status, out.Error.Message)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("openai: %s", res.Status)
+	}
+	if len(out.Choices) == 0 {
+		return "", fmt.Errorf("openai: empty response")
+	}
+	return out.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// vertexGenerator calls Claude on Vertex AI with a gcloud access token.
+type vertexGenerator struct {
+	project  string
+	location string
+	model    string
+	deps     genDeps
+	debug    *zap.Logger
+}
+
+func (g vertexGenerator) Generate(ctx context.Context, req genRequest) (string, error) {
+	token, err := g.deps.tokenSource().Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	g.debug.Debug("googlcloud aiplatform token retrieved")
+	url := fmt.Sprintf("%[2]s/v1/projects/%[3]s/locations/%[4]s/publishers/anthropic/models/%[1]s:streamRawPredict", g.model, g.deps.vertexBaseURL(g.location), g.project, g.location)
+	payload := newClaudeRequest(req)
+	payload.Version = "vertex-2023-10-16"
+	g.debug.Debug("new request for vertexai api",
+		zap.String("anthropic_version", payload.Version),
+		zap.Int("max_tokens", payload.MaxTokens),
+		zap.String("model", g.model),
+		zap.String("location", g.location),
+		zap.String("project_id", g.project),
+	)
+	header := make(http.Header)
+	header.Add("Authorization", "Bearer "+token)
+	return postClaude(ctx, g.deps.httpClient(), url, header, payload, req.stream, g.debug)
+}
@@ -0,0 +1,503 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// CommitMessageGenerator asks a model for a commit message.
+type CommitMessageGenerator interface {
+	Generate(ctx context.Context, req genRequest) (string, error)
+}
+
+type genRequest struct {
+	system      string
+	prompt      string
+	maxTokens   int
+	temperature float64   // zero keeps the generator default
+	stream      io.Writer // receives tokens as they arrive, when the backend streams
+}
+
+var generators = map[string]func(f genFlags, debug *zap.Logger) CommitMessageGenerator{
+	"vertex": func(f genFlags, debug *zap.Logger) CommitMessageGenerator {
+		return vertexGenerator{
+			project:  *f.vertexProject,
+			location: *f.vertexLocation,
+			model:    f.modelOr(*f.vertexModel),
+			deps:     f.deps,
+			debug:    debug,
+		}
+	},
+	"anthropic": func(f genFlags, debug *zap.Logger) CommitMessageGenerator {
+		return anthropicGenerator{
+			baseURL: f.baseURLOr(anthropicBaseURLOf(f.anthropic)),
+			apiKey:  anthropicAPIKey(f.anthropic),
+			version: *f.anthropicVersion,
+			model:   f.modelOr("claude-3-5-sonnet-20241022"),
+			deps:    f.deps,
+			debug:   debug,
+		}
+	},
+	"ollama": func(f genFlags, debug *zap.Logger) CommitMessageGenerator {
+		return ollamaGenerator{
+			host:        *f.host,
+			model:       f.modelOr(*f.ollamaModel),
+			temperature: *f.temperature,
+			numCtx:      *f.numCtx,
+			deps:        f.deps,
+			debug:       debug,
+		}
+	},
+	"openai": func(f genFlags, debug *zap.Logger) CommitMessageGenerator {
+		return openaiGenerator{
+			baseURL: f.baseURLOr("https://api.openai.com/v1"),
+			apiKey:  os.Getenv("OPENAI_API_KEY"),
+			model:   f.modelOr("gpt-4o-mini"),
+			deps:    f.deps,
+			debug:   debug,
+		}
+	},
+}
+
+func providerNames() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// genFlags are the model and style flags shared by the AI commit commands.
+type genFlags struct {
+	provider, model, baseURL                   *string
+	vertexProject, vertexModel, vertexLocation *string
+	anthropicVersion, host, ollamaModel        *string
+	style                                      *string
+	temperature                                *float64
+	numCtx, candidates                         *int
+	explain, signoff                           *bool
+	coAuthors                                  *[]string
+
+	anthropic anthropicConfig // of the configuration
+	deps      genDeps
+}
+
+func (f *genFlags) register(cmd *cobra.Command, provider string) {
+	f.provider = cmd.Flags().String("provider", provider, fmt.Sprintf("commit message provider (%s)", strings.Join(providerNames(), ", ")))
+	f.model = cmd.Flags().String("model", "", "model id, defaults to the provider default")
+	f.baseURL = cmd.Flags().String("base-url", "", "base url of the anthropic or openai compatible api")
+	f.anthropicVersion = cmd.Flags().String("anthropic-version", anthropicVersion, "anthropic-version header of the anthropic api")
+
+	f.style = cmd.Flags().String("style", stylePlain, "commit message style (plain, conventional)")
+	f.candidates = cmd.Flags().Int("candidates", 1, "number of messages to generate and pick from")
+	f.explain = cmd.Flags().Bool("explain", false, "print the message before and after each post-processing stage")
+	f.signoff = cmd.Flags().BoolP("signoff", "s", false, "add a Signed-off-by trailer from git user.name and user.email")
+	f.coAuthors = cmd.Flags().StringSlice("co-author", nil, "add a Co-authored-by trailer for a trailers.roster entry or \"Name <email>\", ? to pick")
+
+	defaults := defaultConfig()
+
+	f.host = cmd.Flags().String("host", "", "ollama host, defaults to OLLAMA_HOST")
+	f.ollamaModel = cmd.Flags().String("ollama-model", defaults.Ollama.Model, "ollama model when --model is not given, and of the extraction pass")
+	f.temperature = cmd.Flags().Float64("temperature", -1, "ollama sampling temperature, negative keeps the model default")
+	f.numCtx = cmd.Flags().Int("num-ctx", 0, "ollama context window size in tokens, zero keeps the model default")
+
+	f.vertexLocation = cmd.Flags().String("vx-location", defaults.Vertex.Location, "vertex ai project location")
+	f.vertexModel = cmd.Flags().String("vx-model", defaults.Vertex.Model, "vertex ai claude sonnet model id")
+	f.vertexProject = cmd.Flags().String("vx-project", defaults.Vertex.Project, "vertex ai project id")
+}
+
+func (f genFlags) modelOr(model string) string {
+	if *f.model != "" {
+		return *f.model
+	}
+	return model
+}
+
+func (f genFlags) baseURLOr(url string) string {
+	if *f.baseURL != "" {
+		return strings.TrimSuffix(*f.baseURL, "/")
+	}
+	return url
+}
+
+func (f genFlags) checkStyle() error {
+	switch *f.style {
+	case stylePlain, styleConventional:
+		return nil
+	}
+	return fmt.Errorf("unknown style %q, want plain or conventional", *f.style)
+}
+
+func (f genFlags) generator(debug *zap.Logger) (CommitMessageGenerator, error) {
+	if err := f.checkStyle(); err != nil {
+		return nil, err
+	}
+	if *f.candidates < 1 {
+		return nil, fmt.Errorf("--candidates %d, want at least 1", *f.candidates)
+	}
+	newGen, ok := generators[*f.provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q, want one of %s", *f.provider, strings.Join(providerNames(), ", "))
+	}
+	return newGen(f, debug.Named(*f.provider)), nil
+}
+
+// switcher returns a function building the generator of another model of
+// the same provider.
+func (f genFlags) switcher(debug *zap.Logger) func(model string) (CommitMessageGenerator, error) {
+	return func(model string) (CommitMessageGenerator, error) {
+		*f.model = model
+		return f.generator(debug)
+	}
+}
+
+// commitFlow is the generation and commit sequence shared by the AI commit
+// commands: staged diff, prompt, generation, post-processing, stash file
+// and final git commit.
+type commitFlow struct {
+	repo         Repo
+	gen          CommitMessageGenerator
+	in           *bufio.Reader // of all the prompts, one reader keeps what is typed ahead
+	out          io.Writer
+	stream       bool   // show the message while it is generated
+	style        string // plain or conventional
+	budget       diffConfig
+	lint         lintConfig
+	tag          tagConfig
+	drafts       draftStore
+	editor       string
+	prompts      promptTemplates
+	deps         genDeps    // of the extraction pass
+	context      promptData // repository part of the prompt data
+	interactive  bool       // review the message before committing
+	useSkim      bool       // pick among candidates with sk
+	pipeline     pipelineConfig
+	explain      bool     // print the before/after of every pipeline stage
+	trailers     []string // of the commit, added by the trailers stage
+	extractModel string   // ollama model of the extract stage, skipped when empty
+	// switchModel returns the generator of another model, to review.
+	switchModel func(model string) (CommitMessageGenerator, error)
+	debug       *zap.Logger
+}
+
+func (f commitFlow) stagedDiff() (string, error) {
+	diff, err := f.repo.DiffCached()
+	if err != nil {
+		return "", err
+	}
+	f.debug.Debug("diff --cached pass", zap.String("diff", diff), zap.Int("len(diff)", len(diff)))
+	return diff, nil
+}
+
+// prompt builds the commit message request from the commit template. A
+// diff over the budget is summarised chunk by chunk first, then the
+// message is asked from the summaries.
+func (f commitFlow) prompt(ctx context.Context, diff string) (genRequest, error) {
+	plan := f.budget.plan(diff)
+	data := f.promptData(diff, plan)
+	if len(plan.chunks) <= 1 {
+		return f.commitRequest(data)
+	}
+	data.Summaries = make([]string, len(plan.chunks))
+	for i, chunk := range plan.chunks {
+		fmt.Fprintf(f.out, "📝 summarising diff chunk %d/%d\n", i+1, len(plan.chunks))
+		req, err := f.summaryRequest(data, chunk, i, len(plan.chunks))
+		if err != nil {
+			return genRequest{}, err
+		}
+		s, err := f.gen.Generate(ctx, req)
+		if err != nil {
+			return genRequest{}, fmt.Errorf("summarise chunk %d/%d: %w", i+1, len(plan.chunks), err)
+		}
+		data.Summaries[i] = strings.TrimSpace(s)
+		f.debug.Debug("diff chunk summarised", zap.Int("chunk", i), zap.String("summary", data.Summaries[i]))
+	}
+	return f.commitRequest(data)
+}
+
+// promptData is the repository context with what the plan keeps of diff.
+func (f commitFlow) promptData(diff string, plan diffPlan) promptData {
+	data := f.context
+	for _, fd := range splitDiff(diff) {
+		data.Files = append(data.Files, fd.path)
+	}
+	data.Omitted = plan.notes
+	if len(plan.chunks) <= 1 {
+		data.Diff = plan.diff()
+	}
+	return data
+}
+
+func (f commitFlow) commitRequest(data promptData) (genRequest, error) {
+	prompt, err := f.prompts.render("commit", data)
+	if err != nil {
+		return genRequest{}, fmt.Errorf("commit prompt: %w", err)
+	}
+	return genRequest{prompt: prompt, maxTokens: 256}, nil
+}
+
+func (f commitFlow) summaryRequest(data promptData, chunk string, i, n int) (genRequest, error) {
+	data.Diff, data.Part, data.Parts = chunk, i+1, n
+	prompt, err := f.prompts.render("summary", data)
+	if err != nil {
+		return genRequest{}, fmt.Errorf("summary prompt: %w", err)
+	}
+	return genRequest{prompt: prompt, maxTokens: 256}, nil
+}
+
+// generate asks for a message for diff.
+func (f commitFlow) generate(ctx context.Context, diff string) (string, error) {
+	req, err := f.prompt(ctx, diff)
+	if err != nil {
+		return "", err
+	}
+	return f.answer(ctx, req, diff)
+}
+
+// answer asks for a message with the prompt built for diff. In
+// conventional style, invalid answers are asked again with the validation
+// error.
+func (f commitFlow) answer(ctx context.Context, req genRequest, diff string) (string, error) {
+	if f.style != styleConventional {
+		return f.ask(ctx, req)
+	}
+	var staged []string // repository relative, unlike status paths
+	for _, fd := range splitDiff(diff) {
+		staged = append(staged, fd.path)
+	}
+	req.system = conventionalSystemPrompt(inferScope(staged))
+	prompt := req.prompt
+	for attempt := 0; ; attempt++ {
+		msg, err := f.ask(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		_, err = parseConventional(msg)
+		if err == nil {
+			return msg, nil
+		}
+		if attempt == conventionalRetries {
+			warn("not a conventional commit: %v", err)
+			return msg, nil
+		}
+		warn("not a conventional commit (%v), asking again", err)
+		req.prompt = fmt.Sprintf("%s\nYour previous answer was:\n\n%s\n\nIt is not a valid Conventional Commit: %v. Answer again with the commit message only.\n", prompt, msg, err)
+	}
+}
+
+// ask sends one request. When streaming, tokens are shown as they arrive,
+// or the whole message once done if the backend does not stream.
+func (f commitFlow) ask(ctx context.Context, req genRequest) (string, error) {
+	var streamed countWriter
+	if f.stream {
+		streamed.w = f.out
+		req.stream = &streamed
+	}
+	msg, err := f.gen.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if f.stream {
+		if streamed.n == 0 {
+			fmt.Fprint(f.out, msg)
+		}
+		fmt.Fprintln(f.out)
+	}
+	msg = strings.TrimSpace(msg)
+	f.debug.Debug("commit message generated", zap.String("response", msg))
+	return msg, nil
+}
+
+const (
+	stylePlain          = "plain"
+	styleConventional   = "conventional"
+	conventionalRetries = 2
+)
+
+// extract runs a second pass with a local model to keep only the commit
+// message from a chatty answer.
+func (f commitFlow) extract(ctx context.Context, msg string) (string, error) {
+	f.debug.Debug("starting llama chat")
+	system, err := f.prompts.render("extract", f.context)
+	if err != nil {
+		return "", fmt.Errorf("extract prompt: %w", err)
+	}
+	out, err := ollamaGenerator{
+		model: f.extractModel,
+		deps:  f.deps,
+		debug: f.debug,
+	}.Generate(ctx, genRequest{
+		system: system,
+		prompt: msg,
+	})
+	return strings.TrimSpace(out), err
+}
+
+// polish runs the post-processing pipeline on a generated message, then
+// warns about the lint problems left.
+func (f commitFlow) polish(ctx context.Context, msg string) (string, error) {
+	p := pipeline{cfg: f.pipeline, lint: f.lint, trailers: f.trailers}
+	if f.extractModel != "" {
+		p.extract = f.extract
+	}
+	msg, reports, err := p.run(ctx, msg)
+	if f.explain {
+		fmt.Fprint(f.out, explain(reports)) // at once, the candidates run together
+	}
+	if err != nil {
+		return "", err
+	}
+	msg = strings.TrimSpace(msg)
+	for _, l := range f.lint.lint(msg) {
+		warn("commit message line %s", l)
+	}
+	return msg, nil
+}
+
+// show prints the message, clearing the screen first when asked.
+func (f commitFlow) show(msg string, clear bool) error {
+	if clear {
+		// Try ANSI first
+		if _, err := fmt.Fprint(f.out, "\033[H\033[2J"); err != nil {
+			// Fallback to OS specific clear
+			var cmd *exec.Cmd
+			if runtime.GOOS == "windows" {
+				cmd = exec.Command("cmd", "/c", "cls")
+			} else {
+				cmd = exec.Command("clear")
+			}
+			cmd.Stdout = f.out
+			if err := cmd.Run(); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintln(f.out, msg)
+	return err
+}
+
+// stash saves the timestamp tag and the message body as a new draft.
+func (f commitFlow) stash(body string) (draft, error) {
+	ts, err := tagNamer{repo: f.repo, cfg: f.tag}.name(false, f.context.Ticket, true)
+	if err != nil {
+		return draft{}, err
+	}
+	msg := fmt.Sprintf("%s\n\n%s\n", ts, body)
+	if f.style == styleConventional {
+		// The subject is the conventional header, the tag moves to a footer.
+		msg = appendFooter(body, tagFooter, ts)
+	}
+	d, err := f.drafts.save(msg)
+	if err != nil {
+		return d, err
+	}
+	f.debug.Debug("write final commit", zap.String("body", body), zap.String("tag", ts), zap.String("draft", d.path))
+	return d, nil
+}
+
+// commit opens the draft in an editor then commits it and removes the
+// draft. In conventional style an invalid message is edited again unless
+// the user insists.
+func (f commitFlow) commit(d draft) error {
+	ed, err := resolveEditor(f.repo, f.editor, os.Getenv)
+	if err != nil {
+		return err
+	}
+	var msg string
+EDIT_LOOP:
+	for {
+		msg, err = ed.edit(d.path)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(d.path, []byte(msg), 0644); err != nil {
+			return err
+		}
+		if f.style != styleConventional {
+			break
+		}
+		if _, err = parseConventional(msg); err == nil {
+			break
+		}
+		fmt.Fprintln(f.out, red("not a conventional commit: "+err.Error()))
+		answer, err := f.question("[e]dit again, [c]ommit anyway or [a]bort? ")
+		if err != nil {
+			return err
+		}
+		switch answer {
+		case "c":
+			break EDIT_LOOP
+		case "a":
+			return fmt.Errorf("commit aborted, the message is kept in %s", d.path)
+		}
+	}
+	{
+		scan := bufio.NewScanner(strings.NewReader(msg))
+		fmt.Fprintln(f.out)
+		fmt.Fprintln(f.out)
+		fmt.Fprintln(f.out, "[EDIT]")
+		fmt.Fprintln(f.out)
+		for scan.Scan() {
+			fmt.Fprintln(f.out, scan.Text())
+		}
+	}
+	return f.commitDraft(d, msg)
+}
+
+// commitDraft commits msg and removes the draft it comes from.
+func (f commitFlow) commitDraft(d draft, msg string) error {
+	if err := f.repo.Commit(commitOpts{file: "-", message: strings.NewReader(msg)}); err != nil {
+		return err
+	}
+	return f.drafts.remove(d)
+}
+
+// question prints q and reads the first letter of the answer.
+func (f commitFlow) question(q string) (string, error) {
+	line, err := f.readLine(q)
+	if err != nil {
+		return "", err
+	}
+	line = strings.ToLower(line)
+	if line == "" {
+		return "", nil
+	}
+	return line[:1], nil
+}
+
+// readLine prints q and reads the answer.
+func (f commitFlow) readLine(q string) (string, error) {
+	fmt.Fprint(f.out, q)
+	line, err := f.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// warn tells the user about a degraded result that is not an error.
+var warn = func(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "%s⚠️  %s%s\n", printYellow, fmt.Sprintf(format, args...), printReset)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
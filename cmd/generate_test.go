@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func Test_genFlags_generator(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: nil, want: "vertex"},
+		{args: []string{"--provider", "openai", "--model", "local"}, want: "openai"},
+		{args: []string{"--provider", "ollama"}, want: "ollama"},
+		{args: []string{"--provider", "anthropic"}, want: "anthropic"},
+		{args: []string{"--provider", "nope"}, wantErr: true},
+	} {
+		var gen genFlags
+		cmd := &cobra.Command{}
+		gen.register(cmd, "vertex")
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		g, err := gen.generator(zap.NewNop())
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%q: err = %v", tt.args, err)
+		}
+		var got string
+		switch g.(type) {
+		case vertexGenerator:
+			got = "vertex"
+		case openaiGenerator:
+			got = "openai"
+			if m := g.(openaiGenerator).model; m != "local" {
+				t.Errorf("model = %q", m)
+			}
+		case ollamaGenerator:
+			got = "ollama"
+		case anthropicGenerator:
+			got = "anthropic"
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func Test_openaiGenerator(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		var req struct {
+			Model    string      `json:"model"`
+			Messages []openaiMsg `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Model != "m" || len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+			t.Errorf("request = %+v", req)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Add status parser"}}]}`))
+	}))
+	defer srv.Close()
+
+	g := openaiGenerator{baseURL: srv.URL + "/v1", model: "m", debug: zap.NewNop()}
+	got, err := g.Generate(context.Background(), genRequest{system: "s", prompt: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Add status parser" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -7,12 +7,19 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func newInstallCommand() *cobra.Command {
+func newInstallCommand(repo Repo) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "install",
 		Aliases: []string{"i"},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var err error
+			cfg, err := loadConfig(repo, cmd)
+			if err != nil {
+				return err
+			}
+			srcDir, err := cfg.srcDir()
+			if err != nil {
+				return err
+			}
 			{
 				if err = os.Chdir(srcDir); err != nil {
 					return err
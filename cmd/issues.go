@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// issuesConfig is the "issues" section of the configuration: how to find
+// the ticket of a branch, and what is known about it offline.
+type issuesConfig struct {
+	// Branch are the regexps tried in turn on the branch name. The ticket
+	// id is the "ticket" group, the first group without it, or the whole
+	// match.
+	Branch []string `yaml:"branch"`
+	// Cache is a JSON or YAML file of the tickets by id, relative to the
+	// root of the repository, or the http url of a local stub answering
+	// GET <url>/<id>. Empty for none.
+	Cache string `yaml:"cache"`
+}
+
+// defaultBranchPatterns find an id such as PROJ-1234 anywhere in the name.
+var defaultBranchPatterns = []string{`(?P<ticket>[A-Z][A-Z0-9]+-[0-9]+)`}
+
+// issueTimeout bounds the call to the issue stub, retries included, it is
+// local.
+const issueTimeout = 5 * time.Second
+
+// ticketID is the ticket id of the branch, "" when no pattern matches.
+// The patterns are checked by the configuration.
+func ticketID(patterns []string, branch string) string {
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			continue
+		}
+		m := re.FindStringSubmatch(branch)
+		switch {
+		case m == nil:
+			continue
+		case re.SubexpIndex("ticket") > 0:
+			return m[re.SubexpIndex("ticket")]
+		case len(m) > 1:
+			return m[1]
+		}
+		return m[0]
+	}
+	return ""
+}
+
+// branchTicket is the ticket of the current branch, "" when detached or
+// without one.
+func branchTicket(repo Repo, cfg issuesConfig) string {
+	s, err := repo.Status()
+	if err != nil || s.branch.detached() {
+		return ""
+	}
+	return ticketID(cfg.Branch, s.branch.head)
+}
+
+// issue is what the cache knows about a ticket.
+type issue struct {
+	Title      string   `yaml:"title" json:"title"`
+	Acceptance []string `yaml:"acceptance" json:"acceptance"` // acceptance criteria
+}
+
+// lookupIssue finds the ticket in the cache, false when it is not there.
+// client asks the stub.
+func lookupIssue(cfg issuesConfig, root, id string, client *http.Client) (issue, bool, error) {
+	if cfg.Cache == "" || id == "" {
+		return issue{}, false, nil
+	}
+	if u, err := url.Parse(cfg.Cache); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return fetchIssue(client, cfg.Cache, id)
+	}
+	name, err := expandHome(cfg.Cache)
+	if err != nil {
+		return issue{}, false, err
+	}
+	if !filepath.IsAbs(name) && root != "" {
+		name = filepath.Join(root, name)
+	}
+	b, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return issue{}, false, nil
+	}
+	if err != nil {
+		return issue{}, false, err
+	}
+	var issues map[string]issue
+	if err = yaml.Unmarshal(b, &issues); err != nil { // JSON is YAML too
+		return issue{}, false, fmt.Errorf("%s: %w", name, err)
+	}
+	is, ok := issues[id]
+	return is, ok, nil
+}
+
+// fetchIssue asks the stub for the ticket.
+func fetchIssue(client *http.Client, base, id string) (issue, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), issueTimeout)
+	defer cancel()
+	endpoint := strings.TrimSuffix(base, "/") + "/" + url.PathEscape(id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return issue{}, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return issue{}, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return issue{}, false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return issue{}, false, fmt.Errorf("%s: %s", endpoint, res.Status)
+	}
+	var is issue
+	if err = json.NewDecoder(res.Body).Decode(&is); err != nil {
+		return issue{}, false, fmt.Errorf("%s: %w", endpoint, err)
+	}
+	return is, true, nil
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func Test_ticketID(t *testing.T) {
+	custom := []string{`^(?:feat|fix)/(\d+)-`, `^(?P<kind>[a-z]+)/(?P<ticket>[a-z]+-\d+)`}
+	tests := []struct {
+		patterns []string
+		branch   string
+		want     string
+	}{
+		{defaultBranchPatterns, "feat/PROJ-1234-thing", "PROJ-1234"},
+		{defaultBranchPatterns, "AB2-7", "AB2-7"},
+		{defaultBranchPatterns, "main", ""},
+		{defaultBranchPatterns, "fix/proj-12", ""},
+		{custom, "fix/42-crash", "42"},
+		{custom, "chore/proj-12-deps", "proj-12"},
+		{custom, "main", ""},
+		{[]string{`[A-Z]+-\d+`}, "x/AB-1", "AB-1"},
+	}
+	for _, tt := range tests {
+		if got := ticketID(tt.patterns, tt.branch); got != tt.want {
+			t.Errorf("ticketID(%q, %q) = %q, want %q", tt.patterns, tt.branch, got, tt.want)
+		}
+	}
+}
+
+func Test_lookupIssue(t *testing.T) {
+	want := issue{Title: "Log in with SSO", Acceptance: []string{"SSO button", "no password form"}}
+	root := t.TempDir()
+	files := map[string]string{
+		"issues.yaml": "PROJ-12:\n  title: Log in with SSO\n  acceptance:\n    - SSO button\n    - no password form\n",
+		"issues.json": `{"PROJ-12": {"title": "Log in with SSO", "acceptance": ["SSO button", "no password form"]}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/issues/PROJ-12" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"title": "Log in with SSO", "acceptance": ["SSO button", "no password form"]}`))
+	}))
+	defer srv.Close()
+
+	for _, cache := range []string{"issues.yaml", "issues.json", srv.URL + "/issues"} {
+		got, ok, err := lookupIssue(issuesConfig{Cache: cache}, root, "PROJ-12", srv.Client())
+		if err != nil || !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: lookupIssue() = %v, %v, %v", cache, got, ok, err)
+		}
+		if _, ok, err = lookupIssue(issuesConfig{Cache: cache}, root, "PROJ-13", srv.Client()); ok || err != nil {
+			t.Errorf("%s: lookupIssue() of an unknown ticket = %v, %v", cache, ok, err)
+		}
+	}
+	if _, ok, err := lookupIssue(issuesConfig{Cache: "missing.yaml"}, root, "PROJ-12", nil); ok || err != nil {
+		t.Errorf("lookupIssue() without the file = %v, %v", ok, err)
+	}
+}
+
+func Test_repoContext_issue(t *testing.T) {
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	repo.status.branch.head = "feat/PROJ-12-sso"
+	if err := os.WriteFile(filepath.Join(repo.root, "issues.yaml"), []byte("PROJ-12:\n  title: Log in with SSO\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultConfig()
+	cfg.Issues.Cache = "issues.yaml"
+	data := repoContext(repo, cfg, genDeps{}, zap.NewNop())
+	if data.Ticket != "PROJ-12" || data.Title != "Log in with SSO" {
+		t.Errorf("ticket %q titled %q", data.Ticket, data.Title)
+	}
+}
+
+func Test_repoContext_issueStub(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"title": "Log in with SSO"}`))
+	}))
+	defer srv.Close()
+	repo := newFakeRepo()
+	repo.status.branch.head = "feat/PROJ-12-sso"
+	cfg := defaultConfig()
+	cfg.Issues.Cache = srv.URL
+	cfg.HTTP.Backoff, cfg.HTTP.MaxBackoff = time.Millisecond, time.Millisecond
+	data := repoContext(repo, cfg, genDeps{client: srv.Client()}.withConfig(cfg), zap.NewNop())
+	if data.Title != "Log in with SSO" || calls != 2 {
+		t.Errorf("title %q after %d call(s), want the stub retried", data.Title, calls)
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// newLintMsgCommand checks a commit message, from a file or stdin. It fails
+// on errors so it can serve as a commit-msg hook:
+//
+//	#!/bin/sh
+//	exec yag lint-msg "$1"
+func newLintMsgCommand(repo Repo, out io.Writer) *cobra.Command {
+	var fixOpt *bool
+	cmd := &cobra.Command{
+		Use:   "lint-msg [file]",
+		Short: "lint a commit message, read from stdin without file",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(repo, cmd)
+			if err != nil {
+				return err
+			}
+			name := "-"
+			if len(args) == 1 {
+				name = args[0]
+			}
+			var b []byte
+			if name == "-" {
+				b, err = io.ReadAll(cmd.InOrStdin())
+			} else {
+				b, err = os.ReadFile(name)
+			}
+			if err != nil {
+				return err
+			}
+			msg := string(b)
+			if *fixOpt {
+				msg = cfg.Lint.fix(msg)
+				if name == "-" {
+					fmt.Fprint(out, msg)
+				} else if err = os.WriteFile(name, []byte(msg), 0644); err != nil {
+					return err
+				}
+			}
+			findings := cfg.Lint.lint(msg)
+			for _, f := range findings {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s:%s\n", name, f)
+			}
+			if failed(findings) {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%s: %d commit message problem(s)", name, len(findings))
+			}
+			return nil
+		},
+	}
+	fixOpt = cmd.Flags().Bool("fix", false, "fix fences, preambles, whitespace, blank lines and wrapping in place, to stdout for stdin")
+	return cmd
+}
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// lintConfig is the "lint" section of the repository configuration.
+type lintConfig struct {
+	SubjectMax int      `yaml:"subject_max"` // default 72
+	BodyWidth  int      `yaml:"body_width"`  // default 72
+	Forbid     []string `yaml:"forbid"`      // phrases forbidden in addition to the default ones, case insensitive
+	Disable    []string `yaml:"disable"`     // rules not checked
+	Warn       []string `yaml:"warn"`        // rules reported without failing
+}
+
+const (
+	ruleEmpty          = "empty"
+	ruleSubjectLength  = "subject-length"
+	ruleBlankLine      = "blank-line"
+	ruleBodyWidth      = "body-width"
+	ruleForbidden      = "forbidden-phrase"
+	ruleCodeFence      = "code-fence"
+	ruleTrailingSpaces = "trailing-whitespace"
+)
+
+// lintRules are the rule names, to disable or downgrade to warnings.
+var lintRules = []string{
+	ruleEmpty, ruleSubjectLength, ruleBlankLine, ruleBodyWidth, ruleForbidden, ruleCodeFence, ruleTrailingSpaces,
+}
+
+// defaultForbidden are the preambles and chatter models wrap messages in.
+var defaultForbidden = []string{
+	"here's a commit message",
+	"here is a commit message",
+	"here's the commit message",
+	"here is the commit message",
+	"here's a good commit message",
+	"here is a good commit message",
+	"commit message:",
+	"as an ai",
+	"i hope this helps",
+	"let me know if",
+}
+
+// scissors is the line git commit --verbose puts above the diff.
+const scissors = "# ------------------------ >8 ------------------------"
+
+// trailerLine is a "Token: value" trailer line.
+var trailerLine = regexp.MustCompile(`^([A-Za-z][\w-]*): (\S.*)$`)
+
+type lintFinding struct {
+	line int // 1 based
+	rule string
+	msg  string
+	warn bool
+}
+
+func (f lintFinding) String() string {
+	level := "error"
+	if f.warn {
+		level = "warning"
+	}
+	return fmt.Sprintf("%d: %s: %s: %s", f.line, level, f.rule, f.msg)
+}
+
+func (c lintConfig) withDefaults() lintConfig {
+	if c.SubjectMax <= 0 {
+		c.SubjectMax = 72
+	}
+	if c.BodyWidth <= 0 {
+		c.BodyWidth = 72
+	}
+	c.Forbid = append(append([]string{}, defaultForbidden...), c.Forbid...)
+	return c
+}
+
+func (c lintConfig) enabled(rule string) bool {
+	return !slices.Contains(c.Disable, rule)
+}
+
+// msgLines splits a message, dropping what git strips: the verbose diff
+// below the scissors line. Comment lines are kept, lint skips them.
+func msgLines(msg string) []string {
+	if i := strings.Index(msg, scissors); i >= 0 {
+		msg = msg[:i]
+	}
+	return strings.Split(strings.TrimRight(msg, "\n"), "\n")
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(line, "#")
+}
+
+// isProse tells if a body line may be wrapped: not code, not a trailer and
+// not a single word such as an url.
+func isProse(line string) bool {
+	return !strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "\t") &&
+		!trailerLine.MatchString(line) && strings.Contains(strings.TrimSpace(line), " ")
+}
+
+// lint checks msg and returns its problems.
+func (c lintConfig) lint(msg string) []lintFinding {
+	c = c.withDefaults()
+	var findings []lintFinding
+	report := func(line int, rule, format string, args ...any) {
+		if c.enabled(rule) {
+			findings = append(findings, lintFinding{
+				line: line,
+				rule: rule,
+				msg:  fmt.Sprintf(format, args...),
+				warn: slices.Contains(c.Warn, rule),
+			})
+		}
+	}
+	subject := 0 // line number of the subject, 0 until found
+	for i, line := range msgLines(msg) {
+		n := i + 1
+		if isComment(line) {
+			continue
+		}
+		if strings.TrimRight(line, " \t") != line {
+			report(n, ruleTrailingSpaces, "trailing whitespace")
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			report(n, ruleCodeFence, "markdown code fence")
+		}
+		lower := strings.ToLower(line)
+		for _, phrase := range c.Forbid {
+			if strings.Contains(lower, strings.ToLower(phrase)) {
+				report(n, ruleForbidden, "forbidden phrase %q", phrase)
+				break
+			}
+		}
+		switch {
+		case subject == 0 && strings.TrimSpace(line) == "":
+			continue
+		case subject == 0:
+			subject = n
+			if l := len([]rune(line)); l > c.SubjectMax {
+				report(n, ruleSubjectLength, "subject is %d characters, max %d", l, c.SubjectMax)
+			}
+		case n == subject+1 && strings.TrimSpace(line) != "":
+			report(n, ruleBlankLine, "no blank line after the subject")
+		case n > subject+1 && len([]rune(line)) > c.BodyWidth && isProse(line):
+			report(n, ruleBodyWidth, "line is %d characters, wrap at %d", len([]rune(line)), c.BodyWidth)
+		}
+	}
+	if subject == 0 {
+		report(1, ruleEmpty, "empty message")
+	}
+	return findings
+}
+
+// failed tells if a finding is not a warning.
+func failed(findings []lintFinding) bool {
+	for _, f := range findings {
+		if !f.warn {
+			return true
+		}
+	}
+	return false
+}
+
+// fix repairs what is mechanical: fences, preamble lines, trailing
+// whitespace, blank lines and body wrapping. Comments and the verbose diff
+// are left as they are.
+func (c lintConfig) fix(msg string) string {
+	c = c.withDefaults()
+	var tail string
+	if i := strings.Index(msg, scissors); i >= 0 {
+		msg, tail = msg[:i], msg[i:]
+	}
+	var lines []string
+	for _, line := range strings.Split(msg, "\n") {
+		if isComment(line) {
+			lines = append(lines, line)
+			continue
+		}
+		line = strings.TrimRight(line, " \t")
+		if c.enabled(ruleCodeFence) && strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+		if c.enabled(ruleForbidden) && c.isPreamble(line) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	var out []string
+	subject := -1
+	for _, line := range lines {
+		blank := strings.TrimSpace(line) == ""
+		switch {
+		case blank && (subject < 0 || out[len(out)-1] == ""):
+			// leading or repeated blank line
+		case subject < 0:
+			if !isComment(line) {
+				subject = len(out)
+			}
+			out = append(out, line)
+		case len(out) == subject+1 && !blank && !isComment(line):
+			out = append(out, "", line)
+		default:
+			out = append(out, line)
+		}
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	if c.enabled(ruleBodyWidth) {
+		out, _ = wrapBody(out, subject, c.BodyWidth)
+	}
+	fixed := strings.Join(out, "\n") + "\n"
+	if tail != "" {
+		fixed += tail
+	}
+	return fixed
+}
+
+// isPreamble tells if the line only introduces the message, like
+// "Here's a commit message for the diff:".
+func (c lintConfig) isPreamble(line string) bool {
+	lower := strings.ToLower(strings.TrimSpace(line))
+	if !strings.HasSuffix(lower, ":") {
+		return false
+	}
+	for _, phrase := range c.Forbid {
+		if strings.Contains(lower, strings.ToLower(phrase)) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapBody wraps the prose lines after the subject line at width, and
+// counts them.
+func wrapBody(lines []string, subject, width int) ([]string, int) {
+	var (
+		out []string
+		n   int
+	)
+	for i, line := range lines {
+		if i > subject && subject >= 0 && !isComment(line) && len([]rune(line)) > width && isProse(line) {
+			out = append(out, wrapLine(line, width)...)
+			n++
+			continue
+		}
+		out = append(out, line)
+	}
+	return out, n
+}
+
+var listItem = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+
+// wrapLine wraps a prose line at width, list items continuing under their
+// text.
+func wrapLine(line string, width int) []string {
+	indent := listItem.FindString(line)
+	if indent == "" {
+		indent = line[:len(line)-len(strings.TrimLeft(line, " "))]
+	}
+	cont := strings.Repeat(" ", len(indent))
+	words := strings.Fields(line[len(indent):])
+	var (
+		out   []string
+		cur   = indent
+		empty = true // no word on cur yet
+	)
+	for _, w := range words {
+		if !empty && len([]rune(cur))+1+len([]rune(w)) > width {
+			out = append(out, cur)
+			cur, empty = cont, true
+		}
+		if !empty {
+			cur += " "
+		}
+		cur, empty = cur+w, false
+	}
+	return append(out, cur)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_lintConfig_lint(t *testing.T) {
+	long := strings.TrimSpace(strings.Repeat("word ", 16)) // 79 characters
+	tests := []struct {
+		name  string
+		cfg   lintConfig
+		msg   string
+		rules []string
+	}{
+		{"clean", lintConfig{}, "add status parser\n\nParse porcelain v2.\n", nil},
+		{"empty", lintConfig{}, "\n# comment\n", []string{ruleEmpty}},
+		{"long subject", lintConfig{}, long + "\n", []string{ruleSubjectLength}},
+		{"subject max", lintConfig{SubjectMax: 10}, "add status parser\n", []string{ruleSubjectLength}},
+		{"no blank line", lintConfig{}, "add parser\nbody\n", []string{ruleBlankLine}},
+		{"body width", lintConfig{}, "add parser\n\n" + long + "\n", []string{ruleBodyWidth}},
+		{"long url", lintConfig{}, "add parser\n\nhttps://example.com/" + strings.Repeat("x", 70) + "\n", nil},
+		{"long trailer", lintConfig{}, "add parser\n\nCo-authored-by: " + long + "\n", nil},
+		{"preamble", lintConfig{}, "Here's a commit message:\n\nadd parser\n", []string{ruleForbidden}},
+		{"custom phrase", lintConfig{Forbid: []string{"WIP"}}, "wip: add parser\n", []string{ruleForbidden}},
+		{"fence", lintConfig{}, "```\nadd parser\n```\n", []string{ruleCodeFence, ruleBlankLine, ruleCodeFence}},
+		{"trailing spaces", lintConfig{}, "add parser \n", []string{ruleTrailingSpaces}},
+		{"disabled", lintConfig{Disable: []string{ruleTrailingSpaces}}, "add parser \n", nil},
+		{"comments and verbose diff", lintConfig{}, "add parser\n\n# " + long + "\n" + scissors + "\n" + long + "\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []string
+			for _, f := range tt.cfg.lint(tt.msg) {
+				rules = append(rules, f.rule)
+			}
+			if strings.Join(rules, ",") != strings.Join(tt.rules, ",") {
+				t.Errorf("rules = %q, want %q", rules, tt.rules)
+			}
+		})
+	}
+}
+
+func Test_lintConfig_lint_warn(t *testing.T) {
+	findings := lintConfig{Warn: []string{ruleTrailingSpaces}}.lint("add parser \n")
+	if len(findings) != 1 || !findings[0].warn || failed(findings) {
+		t.Errorf("findings = %v", findings)
+	}
+}
+
+func Test_lintConfig_fix(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{
+			"model chatter",
+			"Here's a commit message for this diff:\n\n```\nadd status parser  \nParse the porcelain v2 format.\n\n\n```\n",
+			"add status parser\n\nParse the porcelain v2 format.\n",
+		},
+		{
+			"wrap",
+			"add parser\n\n" + strings.Repeat("word ", 16) + "\n- " + strings.Repeat("item ", 15) + "\n",
+			"add parser\n\n" + strings.TrimSpace(strings.Repeat("word ", 14)) + "\nword word\n" +
+				"- " + strings.TrimSpace(strings.Repeat("item ", 14)) + "\n  item\n",
+		},
+		{
+			"comments kept",
+			"add parser\n# Please enter the commit message\n",
+			"add parser\n# Please enter the commit message\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lintConfig{}.fix(tt.msg)
+			if got != tt.want {
+				t.Errorf("fix() =\n%q\nwant\n%q", got, tt.want)
+			}
+			if findings := (lintConfig{}).lint(got); len(findings) > 0 {
+				t.Errorf("fixed message findings = %v", findings)
+			}
+		})
+	}
+}
+
+func Test_newLintMsgCommand(t *testing.T) {
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo.root, repoConfigName), []byte("lint:\n  subject_max: 50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+	if err := os.WriteFile(name, []byte("```\n"+strings.Repeat("x", 40)+"\n```\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut bytes.Buffer
+	cmd := newLintMsgCommand(repo, &out)
+	cmd.SetErr(&errOut)
+	cmd.SetArgs([]string{name})
+	if err := cmd.Execute(); err == nil {
+		t.Fatalf("no error, stderr:\n%s", errOut.String())
+	}
+	if !strings.Contains(errOut.String(), name+":1: error: code-fence") {
+		t.Errorf("stderr:\n%s", errOut.String())
+	}
+
+	cmd = newLintMsgCommand(repo, &out)
+	cmd.SetErr(&errOut)
+	cmd.SetArgs([]string{"--fix", name})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(name); string(b) != strings.Repeat("x", 40)+"\n" {
+		t.Errorf("fixed file = %q", b)
+	}
+
+	cmd = newLintMsgCommand(repo, &out)
+	cmd.SetErr(&errOut)
+	cmd.SetIn(strings.NewReader(strings.Repeat("x", 60) + "\n"))
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err == nil {
+		t.Error("subject_max of .yag.yaml not applied")
+	}
+}
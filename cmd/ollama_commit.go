@@ -2,144 +2,120 @@ package cmd
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"strings"
 
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
-func newOllamaCommitCommand(out io.Writer) *cobra.Command {
+func newOllamaCommitCommand(repo Repo, out io.Writer, deps genDeps) *cobra.Command {
 	var commitDryOpt *bool
+	gen := genFlags{deps: deps}
 	cmd := &cobra.Command{ // very experimental proposal 😇
 		Use:   "commit",
-		Short: "ollama-commit then commit",
+		Short: "generate a commit message (ollama by default) then commit",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			{
-				status, err := stat()
-				if err != nil {
-					return fmt.Errorf("yagstat: %w", err)
-				}
-				var coll [][]string
-				for _, fs := range status {
-					if fs.isStaged() {
-						coll = append(coll, strings.Split(fs.path, string(os.PathSeparator)))
-					}
-				}
-				p := make(map[string]string)
-				for _, path := range coll {
-					for i := 1; i < len(path); i++ {
-						p[path[i]] = path[i-1]
-					}
-				}
-				fmt.Println(p)
-			}
-
-			// DEPTODO requires PATH setup for ollama-commit, ts and vim
-
-			var buf bytes.Buffer
-			w := io.MultiWriter(out, &buf)
-			runCommit := func() error {
-				commit := exec.Command("ollama-commit")
-				commit.Stdout = w
-				commit.Stderr = w
-				if err := commit.Run(); err != nil {
-					return err
-				}
-				return nil
-			}
-			var err error
-			if !*commitDryOpt {
-				if err = runCommit(); err != nil {
-					return err
-				}
-			}
-			tsOut, err := exec.Command("yag", "timestamp").Output()
+			debug, err := zap.NewProduction()
 			if err != nil {
 				return err
 			}
-			if *commitDryOpt {
-				fmt.Println("tag:", tsOut)
-				// DEPFIXME only mimics the behavior of ollama-commit default diff
-				//
-				// Interoperability with it ollama-commit is also limit and is being a problem
-				// Considering to opt for a server/client architecture for instance.
-				var buf bytes.Buffer
-				cmd := exec.Command("git", "diff", "--cached")
-				cmd.Stdout = io.MultiWriter(&buf, os.Stdout)
-				cmd.Stderr = io.MultiWriter(&buf, os.Stderr)
-				if err = cmd.Run(); err != nil {
-					return fmt.Errorf("run git diff command: %w", err)
-				}
-				cmd = exec.Command("pbcopy")
-				cmd.Stdin = strings.NewReader(buf.String())
-				if err = cmd.Run(); err != nil {
-					return fmt.Errorf("copy to os clipboard: pbpaste: %w", err)
-				}
-				fmt.Println("📋 pasted into the os clipboard")
-				return nil
-			}
-			const stashName = ".commit-stash"
-			stash, err := os.Create(stashName)
+			debug = debug.Named("commit")
+
+			cfg, err := loadConfig(repo, cmd) // before the generator, it binds the flags
 			if err != nil {
 				return err
 			}
-			_, err = stash.Write(tsOut)
+			gen.deps = deps.withConfig(cfg)
+			gen.anthropic = cfg.Anthropic
+			g, err := gen.generator(debug)
 			if err != nil {
 				return err
 			}
-			_, err = stash.WriteString("\n\n")
+			drafts, err := openDrafts(repo, cfg.Drafts)
 			if err != nil {
 				return err
 			}
-			_, err = stash.Write(buf.Bytes())
+			prompts, err := loadPrompts(repo, cfg.Prompts)
 			if err != nil {
 				return err
 			}
-			err = stash.Close()
+			flow := commitFlow{
+				repo:         repo,
+				gen:          g,
+				in:           bufio.NewReader(cmd.InOrStdin()),
+				out:          out,
+				stream:       true,
+				style:        *gen.style,
+				budget:       cfg.Diff,
+				lint:         cfg.Lint,
+				tag:          cfg.Tag,
+				drafts:       drafts,
+				editor:       cfg.Editor,
+				prompts:      prompts,
+				deps:         gen.deps,
+				context:      repoContext(repo, cfg, gen.deps, debug),
+				interactive:  isTerminal(os.Stdin),
+				useSkim:      hasSkim(),
+				pipeline:     cfg.Pipeline,
+				explain:      *gen.explain,
+				extractModel: cfg.Pipeline.modelOr(cfg.Ollama.Model),
+				switchModel:  gen.switcher(debug),
+				debug:        debug,
+			}
+
+			diff, err := flow.stagedDiff()
 			if err != nil {
+				return fmt.Errorf("run git diff command: %w", err)
+			}
+			if *commitDryOpt {
+				tsOut, err := tagNamer{repo: repo, cfg: cfg.Tag}.name(false, flow.context.Ticket, false)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(out, "tag:", tsOut)
+				prompt, err := flow.renderPrompt("commit", diff)
+				if err != nil {
+					return err
+				}
+				fmt.Fprint(out, prompt)
+				return copyToClipboard(cfg.Clipboard, prompt, out)
+			}
+			if len(diff) == 0 {
+				fmt.Fprintln(out, "🤔 nothing to commit")
+				return nil
+			}
+			if flow.trailers, err = flow.commitTrailers(cfg.Trailers, *gen.coAuthors); err != nil {
 				return err
 			}
-			edit := exec.Command("vim", stashName)
-			edit.Stdin = os.Stdin
-			edit.Stdout = os.Stdout
-			edit.Stderr = os.Stderr
-			err = edit.Run()
+
+			req, err := flow.prompt(cmd.Context(), diff)
 			if err != nil {
 				return err
 			}
-			stash, err = os.Open(stashName)
+			msg, err := flow.message(cmd.Context(), req, diff, *gen.candidates)
 			if err != nil {
 				return err
 			}
-			var stashCpy bytes.Buffer
-
-			{
-				t := io.TeeReader(stash, &stashCpy)
-				scan := bufio.NewScanner(t)
-				fmt.Println()
-				fmt.Println()
-				fmt.Println("[EDIT]")
-				fmt.Println()
-				for scan.Scan() {
-					fmt.Println(scan.Text())
+			if !flow.interactive {
+				draft, err := flow.stash(msg)
+				if err != nil {
+					return err
 				}
+				return flow.commit(draft)
 			}
-
-			err = stash.Close()
+			if msg, err = flow.review(cmd.Context(), req, diff, msg); err != nil {
+				return err
+			}
+			draft, err := flow.stash(msg)
 			if err != nil {
 				return err
 			}
-
-			return gitCli{
-				infoOut: out,
-				in:      &stashCpy,
-			}.run("commit", "-F", "-")
+			return flow.commitDraft(draft, draft.msg)
 		},
 	}
-	commitDryOpt = cmd.Flags().Bool("dry", false, "disable generation of commit message")
+	commitDryOpt = cmd.Flags().Bool("dry", false, "disable generation, print and copy the prompt instead")
+	gen.register(cmd, "ollama")
 	return cmd
 }
@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"bufio"
-	"bytes"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -12,31 +10,21 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func newOnlyUntrackedFilesCommand(out io.Writer) *cobra.Command {
+func newOnlyUntrackedFilesCommand(repo Repo, out io.Writer) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "u",
 		Short: "only 🎶 untracked files",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var b bytes.Buffer
-			gitRun := gitCli{
-				infoOut: out,
-				cmdOut:  &b,
-			}.run
-			if err := gitRun("status", "--short", "--show-stash"); err != nil {
+			status, err := repo.Status()
+			if err != nil {
 				return err
 			}
-			scanner := bufio.NewScanner(&b)
 			var untracked []string
-			for scanner.Scan() {
-				line := scanner.Text()
-				if cut, found := strings.CutPrefix(line, "?? "); found {
-					untracked = append(untracked, cut)
+			for _, fs := range status.files {
+				if fs.untracked() {
+					untracked = append(untracked, fs.path)
 				}
 			}
-			var err error
-			if err = scanner.Err(); err != nil {
-				return fmt.Errorf("scanner error: %w", err)
-			}
 			isEven := false
 			sort.Strings(untracked)
 			for _, cut := range untracked {
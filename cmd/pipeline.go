@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// pipelineConfig is the "pipeline" section of the configuration: the
+// stages post-processing the generated messages, in order.
+type pipelineConfig struct {
+	Stages   []string `yaml:"stages"`   // defaultStages when empty
+	Cleanup  []string `yaml:"cleanup"`  // s/regexp/replacement/flags rules of the cleanup stage
+	Trailers []string `yaml:"trailers"` // "Token: value" lines of the trailers stage
+	Model    string   `yaml:"model"`    // ollama model of the extract stage, ollama.model when empty
+}
+
+const (
+	stageExtract  = "extract"  // keeps the commit message of a chatty answer, with a model
+	stageFences   = "fences"   // strips markdown code fences
+	stageCleanup  = "cleanup"  // applies the cleanup rules
+	stageWrap     = "wrap"     // wraps the body at lint.body_width
+	stageTidy     = "tidy"     // fixes what lint-msg --fix fixes
+	stageTrailers = "trailers" // merges the configured and commit trailers
+)
+
+// pipelineStages are the stage names, only extract needs a model.
+var pipelineStages = []string{stageExtract, stageFences, stageCleanup, stageWrap, stageTidy, stageTrailers}
+
+// defaultStages are the stages run when none are configured.
+var defaultStages = []string{stageFences, stageTidy, stageTrailers}
+
+// stages are the configured stages, or the default ones.
+func (c pipelineConfig) stages() []string {
+	if len(c.Stages) == 0 {
+		return defaultStages
+	}
+	return c.Stages
+}
+
+// withExtract adds the extract stage first when on, or removes it when off
+// and set on the command line: the default of claude commit --no-llama
+// leaves the configured stages alone.
+func (c pipelineConfig) withExtract(on, set bool) pipelineConfig {
+	has := slices.Contains(c.stages(), stageExtract)
+	switch {
+	case on && !has:
+		c.Stages = append([]string{stageExtract}, c.stages()...)
+	case !on && set && has:
+		c.Stages = slices.DeleteFunc(slices.Clone(c.stages()), func(s string) bool { return s == stageExtract })
+	}
+	return c
+}
+
+// modelOr is the model of the extract stage, model when none is set.
+func (c pipelineConfig) modelOr(model string) string {
+	if c.Model != "" {
+		return c.Model
+	}
+	return model
+}
+
+// subst is a cleanup rule, in the sed form s/regexp/replacement/flags. Any
+// character after the s delimits the parts, it cannot appear in them. The
+// flags are i for a case insensitive match and m for ^ and $ matching at
+// line boundaries. All the matches are replaced, $1 is the first group.
+type subst struct {
+	rule string
+	re   *regexp.Regexp
+	repl string
+}
+
+func parseSubst(rule string) (subst, error) {
+	if len(rule) < 2 || rule[0] != 's' {
+		return subst{}, fmt.Errorf("cleanup rule %q, want s/regexp/replacement/", rule)
+	}
+	parts := strings.Split(rule[2:], rule[1:2])
+	if len(parts) != 3 {
+		return subst{}, fmt.Errorf("cleanup rule %q: want 3 %q delimiters, got %d", rule, rule[1:2], len(parts))
+	}
+	var flags string
+	for _, f := range parts[2] {
+		if f != 'i' && f != 'm' {
+			return subst{}, fmt.Errorf("cleanup rule %q: unknown flag %q, want i or m", rule, f)
+		}
+		flags += string(f)
+	}
+	expr := parts[0]
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return subst{}, fmt.Errorf("cleanup rule %q: %w", rule, err)
+	}
+	return subst{rule: rule, re: re, repl: parts[1]}, nil
+}
+
+// stageReport tells what a stage did to the message.
+type stageReport struct {
+	stage         string
+	before, after string
+	changes       []string // none when the stage changed nothing
+}
+
+// pipeline post-processes the generated messages.
+type pipeline struct {
+	cfg  pipelineConfig
+	lint lintConfig
+	// extract runs the extract stage, nil without a model: the stage is
+	// then skipped.
+	extract func(ctx context.Context, msg string) (string, error)
+	// trailers are added by the trailers stage after the configured ones.
+	trailers []string
+}
+
+// run passes msg through the stages in turn.
+func (p pipeline) run(ctx context.Context, msg string) (string, []stageReport, error) {
+	var reports []stageReport
+	for _, name := range p.cfg.stages() {
+		out, changes, err := p.stage(ctx, name, msg)
+		if err != nil {
+			return "", reports, fmt.Errorf("%s stage: %w", name, err)
+		}
+		reports = append(reports, stageReport{stage: name, before: msg, after: out, changes: changes})
+		msg = out
+	}
+	return msg, reports, nil
+}
+
+// stage runs one stage and tells what it changed.
+func (p pipeline) stage(ctx context.Context, name, msg string) (string, []string, error) {
+	switch name {
+	case stageExtract:
+		if p.extract == nil {
+			return msg, []string{"skipped, no model"}, nil
+		}
+		out, err := p.extract(ctx, msg)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", nil, ctx.Err()
+			}
+			warn("extract stage: %v, keeping the message as is", err)
+			return msg, []string{"failed, message kept"}, nil
+		}
+		return out, changedLines(msg, out), nil
+	case stageFences:
+		return stripFences(msg)
+	case stageCleanup:
+		return p.cleanup(msg)
+	case stageWrap:
+		return p.wrap(msg)
+	case stageTidy:
+		out := p.lint.fix(msg)
+		return out, changedLines(msg, out), nil
+	case stageTrailers:
+		return p.mergeTrailers(msg)
+	}
+	return "", nil, fmt.Errorf("unknown stage, want one of %s", strings.Join(pipelineStages, ", "))
+}
+
+func stripFences(msg string) (string, []string, error) {
+	var (
+		out []string
+		n   int
+	)
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			n++
+			continue
+		}
+		out = append(out, line)
+	}
+	if n == 0 {
+		return msg, nil, nil
+	}
+	return strings.Join(out, "\n"), []string{fmt.Sprintf("removed %d fence line(s)", n)}, nil
+}
+
+func (p pipeline) cleanup(msg string) (string, []string, error) {
+	var changes []string
+	for _, rule := range p.cfg.Cleanup {
+		s, err := parseSubst(rule)
+		if err != nil {
+			return "", nil, err
+		}
+		if n := len(s.re.FindAllStringIndex(msg, -1)); n > 0 {
+			msg = s.re.ReplaceAllString(msg, s.repl)
+			changes = append(changes, fmt.Sprintf("%s matched %d time(s)", s.rule, n))
+		}
+	}
+	return msg, changes, nil
+}
+
+func (p pipeline) wrap(msg string) (string, []string, error) {
+	width := p.lint.withDefaults().BodyWidth
+	lines := strings.Split(msg, "\n")
+	subject := slices.IndexFunc(lines, func(line string) bool {
+		return strings.TrimSpace(line) != "" && !isComment(line)
+	})
+	out, n := wrapBody(lines, subject, width)
+	if n == 0 {
+		return msg, nil, nil
+	}
+	return strings.Join(out, "\n"), []string{fmt.Sprintf("wrapped %d line(s) at %d", n, width)}, nil
+}
+
+// mergeTrailers merges the configured trailers, then the commit ones,
+// into the trailer block.
+func (p pipeline) mergeTrailers(msg string) (string, []string, error) {
+	out, added := mergeTrailers(msg, append(slices.Clone(p.cfg.Trailers), p.trailers...))
+	if len(added) == 0 {
+		return msg, nil, nil
+	}
+	changes := make([]string, len(added))
+	for i, t := range added {
+		changes[i] = "added " + t
+	}
+	return out, changes, nil
+}
+
+// changedLines sums up the line diff of a stage without its own report.
+func changedLines(before, after string) []string {
+	var removed, added int
+	for _, l := range lineDiff(before, after) {
+		switch l[0] {
+		case '-':
+			removed++
+		case '+':
+			added++
+		}
+	}
+	if removed == 0 && added == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("-%d +%d line(s)", removed, added)}
+}
+
+// lineDiff is the line diff of a and b, each line prefixed by "-" when
+// removed, "+" when added and " " when kept. Messages are short, the
+// longest common subsequence table is fine.
+func lineDiff(a, b string) []string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			out = append(out, " "+x[i])
+			i, j = i+1, j+1
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, "-"+x[i])
+			i++
+		default:
+			out = append(out, "+"+y[j])
+			j++
+		}
+	}
+	return out
+}
+
+// explain prints the before/after of every stage, as a line diff.
+func explain(reports []stageReport) string {
+	var b strings.Builder
+	for _, r := range reports {
+		if len(r.changes) == 0 {
+			fmt.Fprintf(&b, "── %s: unchanged\n", r.stage)
+			continue
+		}
+		fmt.Fprintf(&b, "── %s: %s\n", r.stage, strings.Join(r.changes, ", "))
+		if r.before == r.after {
+			continue
+		}
+		for _, l := range lineDiff(strings.TrimRight(r.before, "\n"), strings.TrimRight(r.after, "\n")) {
+			b.WriteString(strings.TrimRight(l[:1]+" "+l[1:], " ") + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_pipeline_run(t *testing.T) {
+	long := strings.TrimSpace(strings.Repeat("word ", 16)) // 79 characters
+	tests := []struct {
+		name    string
+		cfg     pipelineConfig
+		msg     string
+		want    string
+		changes []string // of the last stage
+	}{
+		{"default", pipelineConfig{}, "```\nadd parser\n```\n", "add parser\n", nil},
+		{"fences", pipelineConfig{Stages: []string{stageFences}}, "```text\nadd parser\n```\n", "add parser\n", []string{"removed 2 fence line(s)"}},
+		{"cleanup", pipelineConfig{Stages: []string{stageCleanup}, Cleanup: []string{`s/^(Added|Adds) /Add /m`, `s|\bfoo\b|bar|i`}}, "Adds parser\n\nFOO and foo\n", "Add parser\n\nbar and bar\n", []string{`s/^(Added|Adds) /Add /m matched 1 time(s)`, `s|\bfoo\b|bar|i matched 2 time(s)`}},
+		{"wrap", pipelineConfig{Stages: []string{stageWrap}}, long + "\n\n" + long + "\n", long + "\n\n" + strings.Repeat("word ", 13) + "word\nword word\n", []string{"wrapped 1 line(s) at 72"}},
+		{"tidy", pipelineConfig{Stages: []string{stageTidy}}, "add parser\nbody \n", "add parser\n\nbody\n", []string{"-1 +2 line(s)"}},
+		{"trailers", pipelineConfig{Stages: []string{stageTrailers}, Trailers: []string{"Refs: PROJ-1", "Reviewed-by: Ann"}}, "add parser\n\nRefs: PROJ-1\n", "add parser\n\nRefs: PROJ-1\nReviewed-by: Ann\n", []string{"added Reviewed-by: Ann"}},
+		{"unchanged", pipelineConfig{Stages: []string{stageFences, stageWrap, stageTrailers}}, "add parser\n", "add parser\n", nil},
+		{"extract without model", pipelineConfig{Stages: []string{stageExtract}}, "add parser\n", "add parser\n", []string{"skipped, no model"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reports, err := pipeline{cfg: tt.cfg}.run(context.Background(), tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+			if len(reports) != len(tt.cfg.stages()) {
+				t.Fatalf("%d reports for %d stages", len(reports), len(tt.cfg.stages()))
+			}
+			if last := reports[len(reports)-1]; !reflect.DeepEqual(last.changes, tt.changes) {
+				t.Errorf("%s changes = %q, want %q", last.stage, last.changes, tt.changes)
+			}
+		})
+	}
+}
+
+func Test_pipeline_extract(t *testing.T) {
+	p := pipeline{cfg: pipelineConfig{Stages: []string{stageExtract, stageTidy}}}
+	p.extract = func(context.Context, string) (string, error) { return "add parser", nil }
+	got, reports, err := p.run(context.Background(), "Sure! Here it is:\n\nadd parser")
+	if err != nil || got != "add parser\n" {
+		t.Fatalf("run = %q, %v", got, err)
+	}
+	if want := []string{"-2 +0 line(s)"}; !reflect.DeepEqual(reports[0].changes, want) {
+		t.Errorf("extract changes = %q, want %q", reports[0].changes, want)
+	}
+
+	p.extract = func(context.Context, string) (string, error) { return "", errors.New("connection refused") }
+	got, reports, err = p.run(context.Background(), "add parser")
+	if err != nil || got != "add parser\n" || reports[0].changes[0] != "failed, message kept" {
+		t.Errorf("run after a failed extraction = %q, %v, %v", got, reports, err)
+	}
+}
+
+func Test_pipelineConfig_withExtract(t *testing.T) {
+	tests := []struct {
+		stages  []string
+		on, set bool
+		want    []string
+	}{
+		{nil, false, false, defaultStages},
+		{nil, true, false, []string{stageExtract, stageFences, stageTidy, stageTrailers}},
+		{[]string{stageFences, stageExtract}, true, false, []string{stageFences, stageExtract}},
+		{[]string{stageFences, stageExtract}, false, false, []string{stageFences, stageExtract}},
+		{[]string{stageFences, stageExtract}, false, true, []string{stageFences}},
+	}
+	for _, tt := range tests {
+		if got := (pipelineConfig{Stages: tt.stages}).withExtract(tt.on, tt.set).stages(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("withExtract(%v, %v) of %q = %q, want %q", tt.on, tt.set, tt.stages, got, tt.want)
+		}
+	}
+}
+
+func Test_parseSubst_errors(t *testing.T) {
+	for rule, want := range map[string]string{
+		"":         "want s/regexp/replacement/",
+		"y/a/b/":   "want s/regexp/replacement/",
+		"s/a/b/c/": `want 3 "/" delimiters, got 4`,
+		"s/a/b/g":  `unknown flag 'g'`,
+		"s/(a/b/":  "missing closing )",
+	} {
+		if _, err := parseSubst(rule); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseSubst(%q) error = %v, want %q", rule, err, want)
+		}
+	}
+}
+
+func Test_explain(t *testing.T) {
+	_, reports, err := pipeline{}.run(context.Background(), "```\nadd parser\n```\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "── fences: removed 2 fence line(s)\n" +
+		"- ```\n" +
+		"  add parser\n" +
+		"- ```\n" +
+		"── tidy: unchanged\n" +
+		"── trailers: unchanged\n"
+	if got := explain(reports); got != want {
+		t.Errorf("explain =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_newPolishCommand(t *testing.T) {
+	repo := newFakeRepo()
+	configFiles(t, repo, "pipeline:\n  stages: [extract, cleanup, trailers]\n  cleanup: ['s/Adds/Add/']\n  trailers: ['Refs: PROJ-1']\n", "")
+	var out, stderr bytes.Buffer
+	cmd := newPolishCommand(repo, &out)
+	cmd.SetIn(strings.NewReader("Adds parser\n"))
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"--explain"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Add parser\n\nRefs: PROJ-1\n"; out.String() != want {
+		t.Errorf("message = %q, want %q", out.String(), want)
+	}
+	for _, want := range []string{"── extract: skipped, no model\n", "── cleanup: s/Adds/Add/ matched 1 time(s)\n- Adds parser\n+ Add parser\n", "── trailers: added Refs: PROJ-1\n"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("explain = %q, want %q in it", stderr.String(), want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// newPolishCommand runs the local stages of the post-processing pipeline on
+// a message, from a file or stdin, to try the configuration without a
+// model. The extract stage is skipped, the trailers stage adds the
+// configured trailers but no co-author.
+func newPolishCommand(repo Repo, out io.Writer) *cobra.Command {
+	var explainOpt, writeOpt *bool
+	cmd := &cobra.Command{
+		Use:   "polish [file]",
+		Short: "run the local pipeline stages on a commit message, read from stdin without file",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(repo, cmd)
+			if err != nil {
+				return err
+			}
+			name := "-"
+			if len(args) == 1 {
+				name = args[0]
+			}
+			var b []byte
+			if name == "-" {
+				b, err = io.ReadAll(cmd.InOrStdin())
+			} else {
+				b, err = os.ReadFile(name)
+			}
+			if err != nil {
+				return err
+			}
+			trailers, err := commitTrailers(repo, cfg.Trailers, branchTicket(repo, cfg.Issues), nil)
+			if err != nil {
+				return err
+			}
+			p := pipeline{cfg: cfg.Pipeline, lint: cfg.Lint, trailers: trailers}
+			msg, reports, err := p.run(cmd.Context(), string(b))
+			if *explainOpt {
+				fmt.Fprint(cmd.ErrOrStderr(), explain(reports))
+			}
+			if err != nil {
+				return err
+			}
+			if *writeOpt && name != "-" {
+				return os.WriteFile(name, []byte(msg), 0644)
+			}
+			fmt.Fprint(out, msg)
+			return nil
+		},
+	}
+	explainOpt = cmd.Flags().Bool("explain", false, "print the message before and after each stage to stderr")
+	writeOpt = cmd.Flags().BoolP("write", "w", false, "rewrite the file instead of printing the message")
+	return cmd
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newPromptCommand(repo Repo, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "work with the prompt templates of the AI commit commands",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(newPromptRenderCommand(repo, out))
+	return cmd
+}
+
+func newPromptRenderCommand(repo Repo, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:       "render [commit|summary|extract]",
+		Short:     "print the prompt sent for the staged changes, without calling a model",
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: promptNames,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := firstArg(args)
+			if name == "" {
+				name = "commit"
+			}
+			cfg, err := loadConfig(repo, cmd)
+			if err != nil {
+				return err
+			}
+			prompts, err := loadPrompts(repo, cfg.Prompts)
+			if err != nil {
+				return err
+			}
+			flow := commitFlow{
+				repo:    repo,
+				out:     out,
+				budget:  cfg.Diff,
+				prompts: prompts,
+				context: repoContext(repo, cfg, genDeps{}.withConfig(cfg), zap.NewNop()),
+				debug:   zap.NewNop(),
+			}
+			diff, err := flow.stagedDiff()
+			if err != nil {
+				return err
+			}
+			prompt, err := flow.renderPrompt(name, diff)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprint(out, prompt)
+			return err
+		},
+	}
+}
+
+// renderPrompt renders the named template for diff the way the commit
+// commands would, without calling a model. Over budget, the commit prompt
+// gets placeholders for the chunk summaries and the summary prompt shows
+// the first chunk.
+func (f commitFlow) renderPrompt(name, diff string) (string, error) {
+	if !slices.Contains(promptNames, name) {
+		return "", fmt.Errorf("unknown prompt %q, want one of %s", name, strings.Join(promptNames, ", "))
+	}
+	plan := f.budget.plan(diff)
+	data := f.promptData(diff, plan)
+	if n := len(plan.chunks); n > 1 {
+		fmt.Fprintf(f.out, "the diff is over budget and would be summarised in %d chunks\n", n)
+		if name == "summary" {
+			req, err := f.summaryRequest(data, plan.chunks[0], 0, n)
+			return req.prompt, err
+		}
+		for i := range n {
+			data.Summaries = append(data.Summaries, fmt.Sprintf("<summary of chunk %d/%d>", i+1, n))
+		}
+	}
+	switch name {
+	case "summary":
+		req, err := f.summaryRequest(data, data.Diff, 0, 1)
+		return req.prompt, err
+	case "extract":
+		return f.prompts.render("extract", data)
+	}
+	req, err := f.commitRequest(data)
+	return req.prompt, err
+}
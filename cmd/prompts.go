@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// promptsConfig is the "prompts" section of the configuration. A template
+// given here wins over .yag/prompts/<name>.tmpl in the repository, which
+// wins over the built-in one.
+type promptsConfig struct {
+	Commit  string `yaml:"commit"`  // commit message request
+	Summary string `yaml:"summary"` // summary of a chunk of an over budget diff
+	Extract string `yaml:"extract"` // system prompt of the extraction pass
+	Recent  int    `yaml:"recent"`  // recent commit subjects given as examples
+}
+
+// promptsDir holds the repository prompt templates, relative to the root.
+var promptsDir = filepath.Join(".yag", "prompts")
+
+var promptNames = []string{"commit", "summary", "extract"}
+
+// builtinPrompts are the default templates.
+var builtinPrompts = map[string]string{
+	"commit": `{{with .Subjects}}Recent commit subjects of the repository, follow their style:
+{{range .}}- {{.}}
+{{end}}
+{{end -}}
+{{with .Branch}}The change is on branch {{.}}{{with $.Ticket}} for ticket {{.}}{{end}}.
+{{with $.Title}}The ticket is titled "{{.}}".
+{{end}}{{with $.Acceptance}}Its acceptance criteria are:
+{{range .}}- {{.}}
+{{end}}{{end}}
+{{end -}}
+{{if .Summaries -}}
+Provide a good commit message for the change described by these summaries of its diff:
+
+{{join .Summaries "\n\n"}}
+{{else -}}
+Provide a good commit message for the following diff:
+` + "```diff\n{{.Diff}}\n```" + `
+{{end -}}
+{{with .Omitted}}
+These files changed too, their diff is left out:
+{{range .}}- {{.}}
+{{end}}{{end}}`,
+
+	"summary": `Summarise part {{.Part}} of {{.Parts}} of a diff in a few technical bullet points, naming the files and functions changed:
+` + "```diff\n{{.Diff}}\n```" + `
+`,
+
+	"extract": `You will extract with no editing from
+the given paragraph the commit message.
+
+We need to keep a good level of details and to
+stay technical. Bullet points and syntetic
+process are encouraged but the level of details
+must match or increase what was initially
+provided.
+
+We absolutely need the commit message to be passed
+to [git commit] command cli as if passed with
+[-f] or [-m] with no extra characters`,
+}
+
+// promptData is what the templates see.
+type promptData struct {
+	Diff       string   // staged diff, or the chunk to summarise
+	Files      []string // staged files, repository relative
+	Omitted    []string // files left out of the diff, with their size
+	Summaries  []string // chunk summaries of an over budget diff
+	Part       int      // chunk number, from 1, of the summary template
+	Parts      int      // number of chunks
+	Branch     string   // current branch, empty when detached
+	Ticket     string   // ticket id found in the branch name
+	Title      string   // of the ticket, from the issue cache
+	Acceptance []string // acceptance criteria of the ticket, from the issue cache
+	Subjects   []string // recent commit subjects, the most recent first
+	Tag        string   // yag timestamp tag
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"trim": strings.TrimSpace,
+}
+
+// promptTemplates are the templates in use. The zero value uses the
+// built-in ones.
+type promptTemplates map[string]*template.Template
+
+func parsePrompt(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+}
+
+// loadPrompts resolves each template from the configuration, the
+// repository or the built-in defaults.
+func loadPrompts(repo Repo, cfg promptsConfig) (promptTemplates, error) {
+	root, _ := repo.Root() // no repository templates outside of a repository
+	configured := map[string]string{"commit": cfg.Commit, "summary": cfg.Summary, "extract": cfg.Extract}
+	p := make(promptTemplates)
+	for _, name := range promptNames {
+		text, src := configured[name], "prompts."+name
+		if text == "" && root != "" {
+			file := filepath.Join(root, promptsDir, name+".tmpl")
+			b, err := os.ReadFile(file)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			text, src = string(b), file
+		}
+		if text == "" {
+			text, src = builtinPrompts[name], "built-in "+name
+		}
+		t, err := parsePrompt(name, text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		p[name] = t
+	}
+	return p, nil
+}
+
+// render executes the named template.
+func (p promptTemplates) render(name string, data promptData) (string, error) {
+	t, ok := p[name]
+	if !ok {
+		var err error
+		if t, err = parsePrompt(name, builtinPrompts[name]); err != nil {
+			return "", err
+		}
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// repoContext gathers the repository part of the template data. What git
+// cannot tell, before the first commit for instance, is left empty. The
+// issue stub is called with the client of deps.
+func repoContext(repo Repo, cfg config, deps genDeps, debug *zap.Logger) promptData {
+	var data promptData
+	if s, err := repo.Status(); err != nil {
+		debug.Debug("no branch for the prompt", zap.Error(err))
+	} else if !s.branch.detached() {
+		data.Branch = s.branch.head
+		data.Ticket = ticketID(cfg.Issues.Branch, data.Branch)
+	}
+	root, _ := repo.Root()
+	if is, ok, err := lookupIssue(cfg.Issues, root, data.Ticket, deps.httpClient()); err != nil {
+		warn("issue cache: %v", err) // the prompt does without
+	} else if ok {
+		data.Title, data.Acceptance = is.Title, is.Acceptance
+	}
+	recent := cfg.Prompts.Recent
+	if recent > 0 {
+		subjects, err := repo.Subjects(2 * recent) // some are tags
+		if err != nil {
+			debug.Debug("no recent subjects for the prompt", zap.Error(err))
+		}
+		data.Subjects = styleSubjects(subjects, recent)
+	}
+	tag, err := tagNamer{repo: repo, cfg: cfg.Tag}.name(false, data.Ticket, false)
+	if err != nil {
+		debug.Debug("no tag for the prompt", zap.Error(err))
+	}
+	data.Tag = tag
+	return data
+}
+
+// styleSubjects keeps up to n subjects to follow, without the timestamp
+// tags: they are the subjects of the yag commits not in conventional
+// style, the model would copy them.
+func styleSubjects(subjects []string, n int) []string {
+	now := time.Now()
+	subjects = slices.DeleteFunc(subjects, func(s string) bool {
+		_, err := parseYagTag(s, now)
+		return err == nil
+	})
+	return subjects[:min(n, len(subjects))]
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func Test_promptTemplates_builtin(t *testing.T) {
+	tests := []struct {
+		name string
+		data promptData
+		want string
+	}{
+		{
+			"diff",
+			promptData{Diff: "d", Omitted: []string{"a.sum (3 kB)"}},
+			"Provide a good commit message for the following diff:\n```diff\nd\n```\n\nThese files changed too, their diff is left out:\n- a.sum (3 kB)\n",
+		},
+		{
+			"summaries",
+			promptData{Summaries: []string{"one", "two"}},
+			"Provide a good commit message for the change described by these summaries of its diff:\n\none\n\ntwo\n",
+		},
+		{
+			"context",
+			promptData{Diff: "d", Branch: "feat/PROJ-12-login", Ticket: "PROJ-12", Subjects: []string{"add x", "fix y"}},
+			"Recent commit subjects of the repository, follow their style:\n- add x\n- fix y\n\nThe change is on branch feat/PROJ-12-login for ticket PROJ-12.\n\nProvide a good commit message for the following diff:\n```diff\nd\n```\n",
+		},
+		{
+			"ticket",
+			promptData{Diff: "d", Branch: "feat/PROJ-12-login", Ticket: "PROJ-12", Title: "Log in with SSO", Acceptance: []string{"SSO button", "no password form"}},
+			"The change is on branch feat/PROJ-12-login for ticket PROJ-12.\nThe ticket is titled \"Log in with SSO\".\nIts acceptance criteria are:\n- SSO button\n- no password form\n\nProvide a good commit message for the following diff:\n```diff\nd\n```\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := promptTemplates{}.render("commit", tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loadPrompts(t *testing.T) {
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	dir := filepath.Join(repo.root, promptsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{"commit": "repo {{.Branch}}", "summary": "repo summary"} {
+		if err := os.WriteFile(filepath.Join(dir, name+".tmpl"), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := loadPrompts(repo, promptsConfig{Summary: "config {{.Part}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"commit": "repo main", "summary": "config 2", "extract": builtinPrompts["extract"]} {
+		if got, _ := p.render(name, promptData{Branch: "main", Part: 2}); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "extract.tmpl"), []byte("{{.Nope}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err = loadPrompts(repo, promptsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.render("extract", promptData{}); err == nil {
+		t.Error("unknown field rendered")
+	}
+}
+
+func Test_newPromptRenderCommand(t *testing.T) {
+	repo := newFakeRepo()
+	configFiles(t, repo, "", "")
+	repo.status.branch.head = "feat/PROJ-7-prompts"
+	repo.diff = fakeDiff("cmd/prompts.go", 1, 2)
+	var out bytes.Buffer
+	cmd := newPromptCommand(repo, &out)
+	cmd.SetArgs([]string{"render"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"- add status parser", "ticket PROJ-7", "diff --git a/cmd/prompts.go"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("prompt misses %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func Test_repoContext_subjects(t *testing.T) {
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	repo.log = []string{
+		"Add the drafts command",
+		"Fix the retry of 529 answers",
+		"cmd.dev-PROJ-12-Sun.Oct.18.3.04PM.sub",
+		"cmd.dev-202610181600.00",
+	}
+	cfg := defaultConfig()
+	cfg.Prompts.Recent = 2
+	data := repoContext(repo, cfg, genDeps{}, zap.NewNop())
+	if want := []string{"Fix the retry of 529 answers", "Add the drafts command"}; !slices.Equal(data.Subjects, want) {
+		t.Errorf("subjects = %q, want %q", data.Subjects, want)
+	}
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+var record = flag.Bool("record", false, "record the fixtures of testdata/replay against the real services")
+
+// replayInteraction is a request and its response, as kept in the golden
+// files. Headers of the request are not kept, they hold the credentials.
+type replayInteraction struct {
+	Request struct {
+		Method string          `json:"method"`
+		URL    string          `json:"url"`
+		Body   json.RawMessage `json:"body"`
+	} `json:"request"`
+	Response struct {
+		Status int         `json:"status"`
+		Header http.Header `json:"header"`
+		Body   string      `json:"body"`
+	} `json:"response"`
+}
+
+// replayTransport answers the requests from testdata/replay/<name>.json.
+// With -record, the requests go through to the real services and the file
+// is written again at the end of the test.
+type replayTransport struct {
+	t    *testing.T
+	file string
+	next http.RoundTripper // when recording
+
+	mu           sync.Mutex
+	interactions []replayInteraction
+	used         []bool
+}
+
+// replayClient returns a client replaying, or recording, the fixtures of
+// the test.
+func replayClient(t *testing.T, name string) *http.Client {
+	t.Helper()
+	rt := &replayTransport{t: t, file: filepath.Join("testdata", "replay", name+".json")}
+	if *record {
+		rt.next = http.DefaultTransport
+		t.Cleanup(rt.save)
+		return &http.Client{Transport: rt}
+	}
+	b, err := os.ReadFile(rt.file)
+	if err != nil {
+		t.Fatalf("%v, run the test with -record", err)
+	}
+	if err = json.Unmarshal(b, &rt.interactions); err != nil {
+		t.Fatalf("%s: %v", rt.file, err)
+	}
+	rt.used = make([]bool, len(rt.interactions))
+	t.Cleanup(func() {
+		for i, used := range rt.used {
+			if !used {
+				t.Errorf("%s: interaction %d was not replayed", rt.file, i)
+			}
+		}
+	})
+	return &http.Client{Transport: rt}
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	if rt.next != nil {
+		return rt.recordRoundTrip(req, body)
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	// The first unused match, the candidates are asked in parallel.
+	for i, it := range rt.interactions {
+		if rt.used[i] || it.Request.Method != req.Method || it.Request.URL != req.URL.String() || !jsonEqual(it.Request.Body, body) {
+			continue
+		}
+		rt.used[i] = true
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", it.Response.Status, http.StatusText(it.Response.Status)),
+			StatusCode: it.Response.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     it.Response.Header,
+			Body:       io.NopCloser(bytes.NewBufferString(it.Response.Body)),
+			Request:    req,
+		}, nil
+	}
+	rt.t.Errorf("%s: no recorded answer to %s %s\n%s", rt.file, req.Method, req.URL, body)
+	return nil, fmt.Errorf("no recorded answer to %s %s", req.Method, req.URL)
+}
+
+func (rt *replayTransport) recordRoundTrip(req *http.Request, body []byte) (*http.Response, error) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	res, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var it replayInteraction
+	it.Request.Method = req.Method
+	it.Request.URL = req.URL.String()
+	it.Request.Body = body
+	it.Response.Status = res.StatusCode
+	it.Response.Header = http.Header{"Content-Type": res.Header.Values("Content-Type")}
+	it.Response.Body = string(resBody)
+	rt.mu.Lock()
+	rt.interactions = append(rt.interactions, it)
+	rt.mu.Unlock()
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+	return res, nil
+}
+
+func (rt *replayTransport) save() {
+	b, err := json.MarshalIndent(rt.interactions, "", "  ")
+	if err != nil {
+		rt.t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(rt.file), 0755); err != nil {
+		rt.t.Fatal(err)
+	}
+	if err = os.WriteFile(rt.file, append(b, '\n'), 0644); err != nil {
+		rt.t.Fatal(err)
+	}
+}
+
+// jsonEqual compares two json documents regardless of the formatting.
+func jsonEqual(a, b []byte) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// staticToken is a fixed bearer token.
+type staticToken string
+
+func (s staticToken) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// replayDeps run the generators on the fixtures of the test. Recording
+// needs the real credentials.
+func replayDeps(t *testing.T, name string) genDeps {
+	deps := genDeps{client: replayClient(t, name)}
+	if !*record {
+		deps.token = staticToken("replayed")
+	}
+	return deps
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Repo is the git backend the commands talk to.
+//
+// execRepo runs the git executable; tests use an in-memory fake.
+type Repo interface {
+	// Status returns the working tree status with paths relative to the
+	// current directory.
+	Status() (repoStatus, error)
+	// PrintStatus shows the human readable git status for args.
+	PrintStatus(args ...string) error
+	DiffCached() (string, error)
+	Add(paths ...string) error
+	RestoreStaged(paths ...string) error
+	Commit(opts commitOpts) error
+	Tag(name string) error
+	Push(remote string, refs ...string) error
+	// LogOne returns the last commit in --oneline format.
+	LogOne() (string, error)
+	// LastMessage returns the raw message of the last commit.
+	LastMessage() (string, error)
+	// Subjects returns the subjects of the last n commits, the most recent
+	// first.
+	Subjects(n int) ([]string, error)
+	// Root returns the top level directory of the working tree.
+	Root() (string, error)
+	// GitDir returns the absolute path of the .git directory.
+	GitDir() (string, error)
+	// Config returns the value of a git configuration key, "" when unset.
+	Config(key string) (string, error)
+	// Tags returns the tag names of the repository.
+	Tags() ([]string, error)
+}
+
+type commitOpts struct {
+	file              string    // message file, "-" to read message
+	message           io.Reader // read when file is "-"
+	cleanup           string    // --cleanup mode, git default when empty
+	amend             bool
+	verbose           bool
+	allowEmpty        bool
+	allowEmptyMessage bool
+}
+
+func (o commitOpts) args() []string {
+	args := []string{"commit"}
+	if o.file != "" {
+		args = append(args, "--file", o.file)
+	}
+	if o.cleanup != "" {
+		args = append(args, "--cleanup="+o.cleanup)
+	}
+	if o.verbose {
+		args = append(args, "--verbose")
+	}
+	if o.amend {
+		args = append(args, "--amend")
+	}
+	if o.allowEmpty {
+		args = append(args, "--allow-empty")
+	}
+	if o.allowEmptyMessage {
+		args = append(args, "--allow-empty-message")
+	}
+	return args
+}
+
+type execRepo struct {
+	out    io.Writer
+	errOut io.Writer
+	in     io.Reader
+}
+
+func (r execRepo) command(args ...string) *exec.Cmd {
+	c := exec.Command("git", args...)
+	c.Stdin = r.in
+	if c.Stdin == nil {
+		c.Stdin = os.Stdin
+	}
+	c.Stdout = r.out
+	if c.Stdout == nil {
+		c.Stdout = os.Stdout
+	}
+	c.Stderr = r.errOut
+	if c.Stderr == nil {
+		c.Stderr = os.Stderr
+	}
+	return c
+}
+
+func (r execRepo) run(args ...string) error {
+	return r.command(args...).Run()
+}
+
+func (r execRepo) output(args ...string) ([]byte, error) {
+	var buf bytes.Buffer
+	c := r.command(args...)
+	c.Stdout = &buf
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (r execRepo) Status() (repoStatus, error) {
+	out, err := r.output("status", "--porcelain=v2", "-z", "--branch")
+	if err != nil {
+		return repoStatus{}, err
+	}
+	s, err := parseStatus(out)
+	if err != nil {
+		return s, err
+	}
+	out, err = r.output("rev-parse", "--show-prefix")
+	if err != nil {
+		return s, err
+	}
+	prefix := string(bytes.TrimSpace(out))
+	for i := range s.files {
+		s.files[i].path = relToPrefix(prefix, s.files[i].path)
+		if s.files[i].origPath != "" {
+			s.files[i].origPath = relToPrefix(prefix, s.files[i].origPath)
+		}
+	}
+	return s, nil
+}
+
+func (r execRepo) PrintStatus(args ...string) error {
+	return r.run(append([]string{"status"}, args...)...)
+}
+
+func (r execRepo) DiffCached() (string, error) {
+	out, err := r.output("diff", "--cached")
+	return string(out), err
+}
+
+func (r execRepo) Add(paths ...string) error {
+	return r.run(append([]string{"add", "--"}, paths...)...)
+}
+
+func (r execRepo) RestoreStaged(paths ...string) error {
+	return r.run(append([]string{"restore", "--staged", "--"}, paths...)...)
+}
+
+func (r execRepo) Commit(opts commitOpts) error {
+	if opts.message != nil {
+		r.in = opts.message
+	}
+	return r.run(opts.args()...)
+}
+
+func (r execRepo) Tag(name string) error {
+	return r.run("tag", name)
+}
+
+func (r execRepo) Push(remote string, refs ...string) error {
+	return r.run(append([]string{"push", remote}, refs...)...)
+}
+
+func (r execRepo) LogOne() (string, error) {
+	out, err := r.output("log", "-1", "--oneline")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (r execRepo) LastMessage() (string, error) {
+	out, err := r.output("log", "-1", "--format=%B")
+	return string(out), err
+}
+
+func (r execRepo) Subjects(n int) ([]string, error) {
+	r.errOut = io.Discard // no commits yet
+	out, err := r.output("log", "-n", strconv.Itoa(n), "--format=%s")
+	if err != nil {
+		return nil, err
+	}
+	var subjects []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" {
+			subjects = append(subjects, line)
+		}
+	}
+	return subjects, nil
+}
+
+func (r execRepo) Root() (string, error) {
+	r.errOut = io.Discard // outside of a repository is not an error for all callers
+	out, err := r.output("rev-parse", "--show-toplevel")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (r execRepo) GitDir() (string, error) {
+	out, err := r.output("rev-parse", "--absolute-git-dir")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (r execRepo) Tags() ([]string, error) {
+	out, err := r.output("tag", "--list")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+func (r execRepo) Config(key string) (string, error) {
+	out, err := r.output("config", "--get", key)
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		return "", nil
+	}
+	return strings.TrimSpace(string(out)), err
+}
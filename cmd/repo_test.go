@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		root: "/src/yag",
+		log:  []string{"add status parser cmd.dev-202501021504.05"},
+		status: repoStatus{
+			branch: branchStatus{head: "main"},
+			files: []fstat{
+				{kind: statusOrdinary, staged: '.', unstaged: 'M', path: "cmd/root.go"},
+				{kind: statusOrdinary, staged: 'A', unstaged: '.', path: "cmd/repo.go"},
+				{kind: statusUntracked, staged: '?', unstaged: '?', path: "notes.txt"},
+			},
+		},
+	}
+}
+
+func Test_newRootCommand(t *testing.T) {
+	repo := newFakeRepo()
+	var out bytes.Buffer
+	cmd := newRootCommand(repo, &out)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"staging untracked", "cmd/repo.go", "modified", "cmd/root.go"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "notes.txt") {
+		t.Errorf("untracked file listed:\n%s", out.String())
+	}
+	if !slices.Contains(repo.calls, "print-status -uno") {
+		t.Errorf("calls = %q", repo.calls)
+	}
+}
+
+func Test_newUnstageCommand(t *testing.T) {
+	repo := newFakeRepo()
+	cmd := newUnstageCommand(repo)
+	cmd.SetArgs([]string{"cmd/repo.go"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if f := repo.status.files[1]; !f.untracked() {
+		t.Errorf("cmd/repo.go is still staged: %+v", f)
+	}
+}
+
+func Test_newTagCommand(t *testing.T) {
+	repo := newFakeRepo()
+	var out bytes.Buffer
+	cmd := newTagCommand(repo, &out)
+	cmd.SetArgs([]string{"--remote", "origin"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"root", "last-message", "log-one", "tag cmd.dev-202501021504.05", "push origin cmd.dev-202501021504.05"}
+	if !slices.Equal(repo.calls, want) {
+		t.Errorf("calls = %q, want %q", repo.calls, want)
+	}
+}
+
+func Test_newTagCommand_footer(t *testing.T) {
+	repo := newFakeRepo()
+	repo.commits = []string{"feat(cmd): add tag footer\n\nBody.\n\nRefs: #12\nYag-Tag: cmd.dev-202501021504.05\n"}
+	cmd := newTagCommand(repo, &bytes.Buffer{})
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(repo.tags, []string{"cmd.dev-202501021504.05"}) {
+		t.Errorf("tags = %q", repo.tags)
+	}
+}
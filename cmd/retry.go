@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpConfig is the "http" section of the configuration, for the calls to
+// the model backends and the token endpoint.
+type httpConfig struct {
+	Timeout    time.Duration `yaml:"timeout"`     // per attempt, 0 for none
+	Retries    int           `yaml:"retries"`     // attempts after the first one
+	Backoff    time.Duration `yaml:"backoff"`     // first wait, doubled at each retry
+	MaxBackoff time.Duration `yaml:"max_backoff"` // longest wait, Retry-After included
+}
+
+// statusOverloaded is the Anthropic API answer when it is overloaded.
+const statusOverloaded = 529
+
+// retryStatus are the answers worth another attempt.
+func retryStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, statusOverloaded:
+		return true
+	}
+	return false
+}
+
+// retryTransport times out each attempt and retries the transient failures
+// with an exponential backoff and jitter, or after the Retry-After delay
+// the server asks for.
+type retryTransport struct {
+	next  http.RoundTripper
+	cfg   httpConfig
+	sleep func(ctx context.Context, d time.Duration) error
+	// jitter returns a random duration in [0, d).
+	jitter func(d time.Duration) time.Duration
+}
+
+func newRetryTransport(cfg httpConfig, next http.RoundTripper) *retryTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &retryTransport{
+		next:  next,
+		cfg:   cfg,
+		sleep: sleepCtx,
+		jitter: func(d time.Duration) time.Duration {
+			if d <= 0 {
+				return 0
+			}
+			return rand.N(d)
+		},
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryError tells why the request failed after the last attempt.
+type retryError struct {
+	attempts int
+	elapsed  time.Duration
+	status   int           // of the last answer, 0 after a network error
+	err      error         // of the last attempt, nil after an answer
+	asked    time.Duration // Retry-After over the maximum backoff
+	body     string        // of the last answer, up to 64 KiB: the error of the provider
+}
+
+func (e *retryError) Error() string {
+	var cause string
+	switch {
+	case e.err != nil:
+		cause = e.err.Error()
+	case e.status == statusOverloaded:
+		cause = "the service is overloaded (529)"
+	case e.status == http.StatusTooManyRequests:
+		cause = "rate limited (429)"
+	default:
+		cause = fmt.Sprintf("%d %s", e.status, http.StatusText(e.status))
+	}
+	if body := strings.Join(strings.Fields(e.body), " "); body != "" {
+		cause += ": " + body
+	}
+	if e.asked > 0 {
+		return fmt.Sprintf("%s, the server asks to wait %s, over http.max_backoff", cause, e.asked)
+	}
+	return fmt.Sprintf("%s, gave up after %d attempts in %s, try again later or raise http.retries", cause, e.attempts, e.elapsed.Round(time.Millisecond))
+}
+
+func (e *retryError) Unwrap() error { return e.err }
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errors.New("cannot retry a request without GetBody")
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		res, err := t.attempt(req)
+		if req.Context().Err() != nil {
+			return res, err // canceled by the caller, not a transient failure
+		}
+		if err == nil && !retryStatus(res.StatusCode) {
+			return res, nil
+		}
+		fail := &retryError{attempts: attempt + 1, err: err}
+		var asked time.Duration
+		if err == nil {
+			fail.status = res.StatusCode
+			asked = retryAfter(res.Header, time.Now())
+			body, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10)) // keeps the connection too
+			fail.body = string(body)
+			res.Body.Close()
+		}
+		if asked > t.cfg.MaxBackoff || attempt >= t.cfg.Retries {
+			if asked > t.cfg.MaxBackoff {
+				fail.asked = asked
+			}
+			fail.elapsed = time.Since(start)
+			return nil, fail
+		}
+		wait := max(t.backoff(attempt), asked)
+		warn("%s %s: %v, retrying in %s", req.Method, req.URL.Host, failCause(fail), wait.Round(time.Millisecond))
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// failCause is the error without the retry advice, between attempts.
+func failCause(e *retryError) string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("%d %s", e.status, http.StatusText(e.status))
+}
+
+// attempt sends req once within the configured timeout. The timeout covers
+// the body too, it ends when the body is closed.
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.cfg.Timeout <= 0 {
+		return t.next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.cfg.Timeout)
+	res, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("no answer within http.timeout %s: %w", t.cfg.Timeout, err)
+		}
+		return nil, err
+	}
+	res.Body = cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// backoff is the wait before attempt+1: the base doubled at each attempt,
+// up to the maximum, half of it random.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.cfg.Backoff << attempt
+	if d > t.cfg.MaxBackoff || d <= 0 {
+		d = t.cfg.MaxBackoff
+	}
+	return d/2 + t.jitter(d/2)
+}
+
+// retryAfter reads the Retry-After header, delay seconds or http date.
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(s, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
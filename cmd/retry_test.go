@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingServer answers with the statuses in turn, and its headers, then
+// with 200 and the request body. A zero status drops the connection.
+type failingServer struct {
+	statuses []int
+	header   http.Header
+	body     string        // of the failed answers
+	delay    time.Duration // before answering the first attempt
+
+	mu       sync.Mutex
+	attempts int
+}
+
+func (s *failingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.attempts++
+	attempt := s.attempts
+	s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	if attempt == 1 && s.delay > 0 {
+		select {
+		case <-time.After(s.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if len(s.statuses) >= attempt {
+		status := s.statuses[attempt-1]
+		if status == 0 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, s.body)
+		return
+	}
+	w.Write(body)
+}
+
+func Test_retryTransport(t *testing.T) {
+	cfg := httpConfig{Timeout: time.Second, Retries: 3, Backoff: 100 * time.Millisecond, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		name      string
+		srv       *failingServer
+		cfg       httpConfig
+		wantWaits []time.Duration
+		wantErr   string
+	}{
+		{"ok", &failingServer{}, cfg, nil, ""},
+		{"transient", &failingServer{statuses: []int{503, 502, 500}}, cfg, []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond}, ""},
+		{"dropped connection", &failingServer{statuses: []int{0}}, cfg, []time.Duration{50 * time.Millisecond}, ""},
+		{"retry after", &failingServer{statuses: []int{429}, header: http.Header{"Retry-After": {"3"}}}, cfg, []time.Duration{3 * time.Second}, ""},
+		{"retry after over max", &failingServer{statuses: []int{429}, header: http.Header{"Retry-After": {"60"}}}, cfg, nil, "rate limited (429), the server asks to wait 1m0s, over http.max_backoff"},
+		{"overloaded", &failingServer{statuses: []int{529, 529}}, httpConfig{Retries: 1, Backoff: time.Second, MaxBackoff: 1500 * time.Millisecond}, []time.Duration{500 * time.Millisecond}, "the service is overloaded (529), gave up after 2 attempts"},
+		{"provider error", &failingServer{statuses: []int{529}, body: `{"type": "error",` + "\n" + ` "error": {"type": "overloaded_error", "message": "Overloaded"}}`}, httpConfig{Backoff: time.Millisecond, MaxBackoff: time.Millisecond}, nil, `the service is overloaded (529): {"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}, gave up after 1 attempts`},
+		{"not transient", &failingServer{statuses: []int{400}}, cfg, nil, ""},
+		{"timeout", &failingServer{delay: time.Minute}, httpConfig{Timeout: 50 * time.Millisecond, Retries: 1, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}, []time.Duration{500 * time.Microsecond}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.srv)
+			defer srv.Close()
+			var waits []time.Duration
+			rt := newRetryTransport(tt.cfg, nil)
+			rt.jitter = func(time.Duration) time.Duration { return 0 }
+			rt.sleep = func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+			res, err := (&http.Client{Transport: rt}).Post(srv.URL, "text/plain", strings.NewReader("payload"))
+			if tt.wantErr != "" {
+				var retryErr *retryError
+				if !errors.As(err, &retryErr) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(res.Body)
+				res.Body.Close()
+				if len(tt.srv.statuses) == 0 || tt.srv.statuses[0] != 400 {
+					if string(body) != "payload" {
+						t.Errorf("body = %q, want the request body again", body)
+					}
+				} else if res.StatusCode != 400 || tt.srv.attempts != 1 {
+					t.Errorf("status %d after %d attempts", res.StatusCode, tt.srv.attempts)
+				}
+			}
+			if !reflect.DeepEqual(waits, tt.wantWaits) {
+				t.Errorf("waits = %v, want %v", waits, tt.wantWaits)
+			}
+		})
+	}
+}
+
+func Test_retryTransport_canceled(t *testing.T) {
+	srv := httptest.NewServer(&failingServer{statuses: []int{503, 503}})
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	rt := newRetryTransport(httpConfig{Retries: 5, Backoff: time.Hour, MaxBackoff: time.Hour}, nil)
+	rt.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleepCtx(ctx, d)
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := (&http.Client{Transport: rt}).Do(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	for v, want := range map[string]time.Duration{
+		"":                              0,
+		"7":                             7 * time.Second,
+		"Thu, 02 Jan 2025 15:04:35 GMT": 30 * time.Second,
+		"Thu, 02 Jan 2025 15:00:00 GMT": 0,
+		"soon":                          0,
+	} {
+		if got := retryAfter(http.Header{"Retry-After": {v}}, now); got != want {
+			t.Errorf("retryAfter(%q) = %s, want %s", v, got, want)
+		}
+	}
+}
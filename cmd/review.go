@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+const reviewKeys = "[a]ccept [e]dit [r]egenerate [g]uide [m]odel [s]horten [p]revious [n]ext [q]uit? "
+
+// isTerminal tells if f is a terminal, the review needs one to read the
+// answers. /dev/null is a character device too.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(fi, null)
+}
+
+// review shows the generated message and lets the user accept it, edit
+// it, ask for another one, optionally with guidance or from another model,
+// shorten it or abort. Every candidate of the session is kept and can be
+// shown again. It returns the accepted message.
+func (f commitFlow) review(ctx context.Context, req genRequest, diff, msg string) (string, error) {
+	candidates := []string{msg}
+	cur := 0
+	for {
+		fmt.Fprintf(f.out, "\n── candidate %d/%d ──\n%s\n\n", cur+1, len(candidates), candidates[cur])
+		key, err := f.question(reviewKeys)
+		if err != nil {
+			return "", err
+		}
+		var next string
+		switch key {
+		case "a", "y":
+			return candidates[cur], nil
+		case "q", "x":
+			d, err := f.stash(candidates[cur])
+			if err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("commit aborted, the message is kept in %s", d.path)
+		case "p":
+			cur = max(cur-1, 0)
+			continue
+		case "n":
+			cur = min(cur+1, len(candidates)-1)
+			continue
+		case "e":
+			next, err = f.editCandidate(candidates[cur])
+		case "r":
+			next, err = f.regenerate(ctx, req, diff)
+		case "g":
+			var guidance string
+			if guidance, err = f.readLine("guidance: "); err != nil {
+				return "", err
+			}
+			guided := req
+			guided.prompt += fmt.Sprintf("\nTake this guidance into account: %s\n", guidance)
+			next, err = f.regenerate(ctx, guided, diff)
+		case "m":
+			var model string
+			if model, err = f.readLine("model: "); err != nil {
+				return "", err
+			}
+			if f.switchModel == nil {
+				warn("switching model is not supported here")
+				continue
+			}
+			gen, genErr := f.switchModel(model)
+			if genErr != nil {
+				warn("%v", genErr)
+				continue
+			}
+			f.gen = gen
+			next, err = f.regenerate(ctx, req, diff)
+		case "s":
+			next, err = f.regenerate(ctx, shortenPrompt(candidates[cur]), diff)
+		default:
+			continue
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil {
+			warn("%v", err) // keep the session going, the candidates are still there
+			continue
+		}
+		candidates = append(candidates, next)
+		cur = len(candidates) - 1
+	}
+}
+
+// regenerate asks for a new candidate.
+func (f commitFlow) regenerate(ctx context.Context, req genRequest, diff string) (string, error) {
+	msg, err := f.answer(ctx, req, diff)
+	if err != nil {
+		return "", err
+	}
+	return f.polish(ctx, msg)
+}
+
+// editCandidate opens msg in the editor through a draft removed once read.
+func (f commitFlow) editCandidate(msg string) (string, error) {
+	ed, err := resolveEditor(f.repo, f.editor, os.Getenv)
+	if err != nil {
+		return "", err
+	}
+	d, err := f.drafts.save(msg)
+	if err != nil {
+		return "", err
+	}
+	edited, err := ed.edit(d.path)
+	if err != nil {
+		return "", err
+	}
+	return edited, f.drafts.remove(d)
+}
+
+func shortenPrompt(msg string) genRequest {
+	return genRequest{
+		prompt:    fmt.Sprintf("Shorten this commit message to a subject of at most 50 characters and a body of at most three short lines, keeping the facts. Answer with the commit message only:\n\n%s\n", msg),
+		maxTokens: 256,
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func Test_commitFlow_review(t *testing.T) {
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	gen := &answersGenerator{answers: []string{"second", "third", "fourth"}}
+	other := &answersGenerator{answers: []string{"fifth"}}
+	var switched string
+	var out strings.Builder
+	flow := commitFlow{
+		repo: repo,
+		gen:  gen,
+		in:   bufio.NewReader(strings.NewReader("r\ng\nmention the migration\np\ns\nm\nother-model\np\na\n")),
+		out:  &out,
+		switchModel: func(model string) (CommitMessageGenerator, error) {
+			switched = model
+			return other, nil
+		},
+		debug: zap.NewNop(),
+	}
+	req := genRequest{prompt: "diff", maxTokens: 256}
+	got, err := flow.review(context.Background(), req, "diff", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "fourth" {
+		t.Errorf("accepted %q, want fourth\n%s", got, out.String())
+	}
+	if !strings.Contains(gen.reqs[1].prompt, "mention the migration") {
+		t.Errorf("guidance not in prompt:\n%s", gen.reqs[1].prompt)
+	}
+	if !strings.Contains(gen.reqs[2].prompt, "Shorten") || !strings.Contains(gen.reqs[2].prompt, "second") {
+		t.Errorf("shorten prompt:\n%s", gen.reqs[2].prompt)
+	}
+	if switched != "other-model" || len(other.reqs) != 1 {
+		t.Errorf("model switched to %q, %d requests", switched, len(other.reqs))
+	}
+	if !strings.Contains(out.String(), "candidate 5/5") {
+		t.Errorf("candidates not kept:\n%s", out.String())
+	}
+}
+
+func Test_commitFlow_review_abort(t *testing.T) {
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	drafts, err := openDrafts(repo, draftsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow := commitFlow{
+		repo:   repo,
+		in:     bufio.NewReader(strings.NewReader("q\n")),
+		out:    &strings.Builder{},
+		drafts: drafts,
+		debug:  zap.NewNop(),
+	}
+	if _, err = flow.review(context.Background(), genRequest{prompt: "diff", maxTokens: 256}, "diff", "first"); err == nil || !strings.Contains(err.Error(), drafts.dir) {
+		t.Errorf("error = %v, want the draft kept", err)
+	}
+	if d, err := drafts.find(""); err != nil || !strings.HasSuffix(d.msg, "first\n") {
+		t.Errorf("draft = %q, %v", d.msg, err)
+	}
+}
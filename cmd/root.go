@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -9,58 +8,48 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
 )
 
-var srcDir = os.Getenv("YAG_SRCDIR")
-
 func NewCLI() *cobra.Command {
 
-	if srcDir == "" {
-		h, err := os.UserHomeDir()
-		if err != nil {
-			panic(err)
-
-		}
-		srcDir = filepath.Join(h, "i", "wd", "yag")
-	}
-
 	out := os.Stdout
 
-	git := gitCli{
-		infoOut: os.Stdout,
-	}.run
+	repo := execRepo{out: out}
 
-	rootCmd := newRootCommand(git, out)
+	rootCmd := newRootCommand(repo, out)
 
-	skCmd := newSkimCommand()
+	skCmd := newSkimCommand(repo, runSelf)
 
-	unstageCmd := newUnstageCommand(git)
+	unstageCmd := newUnstageCommand(repo)
 
-	uCmd := newOnlyUntrackedFilesCommand(out)
-	unoCmd := newUntrackedNoCommand(git)
+	uCmd := newOnlyUntrackedFilesCommand(repo, out)
+	unoCmd := newUntrackedNoCommand(repo)
 
-	tsCmd := newTimestampCodeCommand()
-	tsLittCmd := newTimestampLitterateCommand()
+	tsCmd := newTimestampCodeCommand(repo, out)
+	tsLittCmd := newTimestampLitterateCommand(repo, out)
 
 	tfCmd := newTerraformCommand()
 	rootCmd.AddCommand(tfCmd)
 	tfGithubCmd := newGithubTerraformCommand()
 	tfCmd.AddCommand(tfGithubCmd)
 
-	installCmd := newInstallCommand()
+	installCmd := newInstallCommand(repo)
 
-	commitCmd := newOllamaCommitCommand(out)
+	commitCmd := newOllamaCommitCommand(repo, out, genDeps{})
 
-	tagCmd := newTagCommand(git, out)
+	tagCmd := newTagCommand(repo, out)
 
 	claudeCmd := newClaudeCommand()
-	claudeCommitCmd := newClaudeCommitCommand()
-
-	testCmd := newTestCommand()
+	claudeCommitCmd := newClaudeCommitCommand(repo, out, genDeps{})
+
+	testCmd := newTestCommand(repo)
+	lintMsgCmd := newLintMsgCommand(repo, out)
+	configCmd := newConfigCommand(repo, out)
+	draftsCmd := newDraftsCommand(repo, out)
+	promptCmd := newPromptCommand(repo, out)
+	polishCmd := newPolishCommand(repo, out)
 	// TODO subsidiary test commands
 
 	rootCmd.AddCommand(
@@ -74,9 +63,14 @@ func NewCLI() *cobra.Command {
 		claudeCmd,
 		uCmd,
 		testCmd,
+		lintMsgCmd,
+		configCmd,
+		draftsCmd,
+		promptCmd,
+		polishCmd,
 	)
 	claudeCmd.AddCommand(claudeCommitCmd)
-	tsCmd.AddCommand(tsLittCmd)
+	tsCmd.AddCommand(tsLittCmd, newTimestampParseCommand(out), newTimestampListCommand(repo, out))
 
 	yagRootCmd := newGitRootCommand()
 	rootCmd.AddCommand(yagRootCmd)
@@ -84,73 +78,18 @@ func NewCLI() *cobra.Command {
 	return rootCmd
 }
 
-type fstat struct {
-	staged   byte
-	unstaged byte
-	path     string
-}
-
-func (fs fstat) untrackedNewFile() bool {
-	return fs.staged == 'A' && fs.unstaged == ' '
-}
-
-func (fs fstat) modified() bool {
-	return fs.unstaged == 'M' || fs.staged == 'M'
-}
-
-func (fs fstat) untracked() bool {
-	return fs.unstaged == '?' && fs.staged == '?'
-}
-
-func (fs fstat) isStaged() bool {
-	return fs.staged != ' '
-}
-
-type gitCli struct {
-	infoOut io.Writer
-	cmdOut  io.Writer
-	in      io.Reader
-}
-
-func (gc gitCli) run(args ...string) error {
-	if len(args) == 0 {
-		fmt.Fprintln(gc.infoOut, "We are looking for changes in current directory:")
-		fmt.Fprintln(gc.infoOut)
-		args = []string{"status", "-uno", "."}
-	}
-	if gc.in == nil {
-		gc.in = os.Stdin
-	}
-	if gc.cmdOut == nil {
-		gc.cmdOut = os.Stdout
-	}
-	cmd := exec.Command("git", args...)
-	cmd.Stdout = gc.cmdOut
-	cmd.Stdin = gc.in
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
-func stat() ([]fstat, error) {
-	c := exec.Command("git", "status", "-s")
-	c.Stderr = os.Stderr
-	var buf bytes.Buffer
-	c.Stdout = &buf
-	err := c.Run()
+// runSelf runs a yag subcommand with the current executable, so it does
+// not depend on a yag binary in PATH.
+func runSelf(args ...string) error {
+	self, err := os.Executable()
 	if err != nil {
-		return nil, err
-	}
-	var status []fstat
-	scanner := bufio.NewScanner(&buf)
-	for scanner.Scan() {
-		t := scanner.Text()
-		status = append(status, fstat{
-			staged:   t[0],
-			unstaged: t[1],
-			path:     t[3:],
-		})
+		return err
 	}
-	return status, nil
+	x := exec.Command(self, args...)
+	x.Stdout = os.Stdout
+	x.Stderr = os.Stderr
+	x.Stdin = os.Stdin
+	return x.Run()
 }
 
 var gitRoot = func() (gitroot, cd string, err error) {
@@ -172,60 +111,6 @@ var gitRoot = func() (gitroot, cd string, err error) {
 	return
 }
 
-func timestamp(litt bool) error {
-	tsfmt := "200601021504.05"
-	if litt {
-		tsfmt = "Mon.Jan.2.34PM"
-	}
-	tstr := time.Now().Format(tsfmt)
-	var (
-		gitroot, cd string
-		err         error
-	)
-
-	gitroot, cd, err = gitRoot()
-	if err != nil {
-		return fmt.Errorf("gitroot: %w", err)
-	}
-	{
-		var part1, part2 string
-		cdpath := strings.Split(cd, string(os.PathSeparator))
-		rootpath := strings.Split(gitroot, string(os.PathSeparator))
-		delta := len(cdpath) - len(rootpath)
-		if delta == 0 { // we are in git root directory
-			part1 = "root"
-		}
-		if delta >= 1 { // only one depth: root of sub directory of the root
-			part1 = cdpath[len(rootpath)]
-		}
-		if delta >= 2 {
-			l := 2
-			if delta == 2 {
-				l = 1
-			}
-			part2 = strings.Join(cdpath[len(cdpath)-l:], ".")
-		}
-		tag := fmt.Sprintf("%s.dev-%s.%s", part1, tstr, part2)
-
-		// Remove extra dot character
-		if tag[len(tag)-1] == '.' {
-			tag = tag[:len(tag)-1]
-		}
-
-		fmt.Println(tag)
-	}
-	return nil
-}
-
-type tstampFormat struct{ litt bool }
-
-func (tsf tstampFormat) print() error {
-	if err := timestamp(tsf.litt); err != nil {
-		return err
-	}
-	return nil
-}
-
 type claudeMsg struct {
 	Role    string             `json:"role"`
 	Content []claudeMsgContent `json:"content"`
@@ -312,7 +197,7 @@ func (u printUtil) greenOnBlack() {
 	)
 }
 
-func newRootCommand(git func(args ...string) error, out io.Writer) *cobra.Command {
+func newRootCommand(repo Repo, out io.Writer) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "yag -- [file]*",
 		Short: "Yet Another [Git]",
@@ -339,17 +224,16 @@ func newRootCommand(git func(args ...string) error, out io.Writer) *cobra.Comman
 							fmt.Fprintf(out, "\033[33m%s\033[0m \033[32m%q\033[0m\n", note, filepath.Join(fname, e.Name()))
 						}
 						fmt.Fprintln(out)
-						return git("status", fname)
+						return repo.PrintStatus(fname)
 					}
 				}
-				// Prepare git command args.
-				gitArgs := append([]string{"add"}, args...)
-				return git(gitArgs...)
+				return repo.Add(args...)
 			}
-			stats, err := stat()
+			status, err := repo.Status()
 			if err != nil {
-				return fmt.Errorf("git stat(): %w", err)
+				return fmt.Errorf("git status: %w", err)
 			}
+			stats := status.files
 			{
 				u := make([]fstat, 0, len(stats))
 				m := make([]fstat, 0, len(stats))
@@ -362,37 +246,34 @@ func newRootCommand(git func(args ...string) error, out io.Writer) *cobra.Comman
 					}
 				}
 				if len(u) > 0 {
-					fmt.Println()
-					fmt.Print("💣 ")
+					fmt.Fprintln(out)
+					fmt.Fprint(out, "💣 ")
 					printUtil{
 						out:       out,
 						cut:       "staging untracked",
 						noNewLine: true,
 					}.yellowOnBlack()
-					fmt.Println(" files:")
+					fmt.Fprintln(out, " files:")
 					for _, f := range u {
-						fmt.Println(f.path)
+						fmt.Fprintln(out, f.path)
 					}
 				}
 				if len(m) > 0 {
-					fmt.Println()
-					fmt.Print("🧨 unstaged ")
+					fmt.Fprintln(out)
+					fmt.Fprint(out, "🧨 unstaged ")
 					printUtil{
 						out:       out,
 						cut:       "modified",
 						noNewLine: true,
 					}.greenOnBlack()
-					fmt.Println(" files:")
-					fmt.Println()
+					fmt.Fprintln(out, " files:")
+					fmt.Fprintln(out)
 					for _, f := range m {
 						printUtil{out: out, cut: f.path}.greenOnBlack()
 					}
 
-					fmt.Println("\n💥💥💥💥💥")
-					xc := exec.Command("git", "status", "-uno")
-					xc.Stdout = os.Stdout
-					xc.Stderr = os.Stderr
-					if err = xc.Run(); err != nil {
+					fmt.Fprintln(out, "\n💥💥💥💥💥")
+					if err = repo.PrintStatus("-uno"); err != nil {
 						return err
 					}
 				}
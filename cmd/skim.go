@@ -3,7 +3,9 @@ package cmd
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -11,7 +13,7 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func newSkimCommand() *cobra.Command {
+func newSkimCommand(repo Repo, yag func(args ...string) error) *cobra.Command {
 
 	var listUntrackedOpt *bool
 
@@ -23,55 +25,35 @@ func newSkimCommand() *cobra.Command {
 		INSTR_LOOP:
 			for {
 				buf.Reset()
-				status, err := stat()
+				status, err := repo.Status()
 				if err != nil {
 					return fmt.Errorf("yag_stat: %w", err)
 				}
-				for _, fs := range status {
+				for _, fs := range status.files {
 					if fs.modified() || (*listUntrackedOpt && fs.untracked()) {
 						buf.WriteString(fs.path + "\n")
 					}
 				}
 				buf.WriteString("help\ndone\ntag-last-commit\nclaude-commit\nclaude-commit-llamax\n")
-				c := exec.Command("sk")
-				c.Stdin = &buf
-				var outBuf bytes.Buffer
-				c.Stdout = &outBuf
-				c.Stderr = os.Stderr
-				err = c.Run()
-				if err != nil {
-					if exiterr, ok := err.(*exec.ExitError); ok {
-						switch exiterr.ExitCode() {
-						case 1:
-							// no match
-							fmt.Println("exit no match")
-							return nil
-						case 2:
-							// error
-							return err
-						case 130:
-							// interrupted (C-C or ESC)
-							fmt.Println("exit interrupted")
-							return nil
-						}
-					}
+				skInstr, err := skim(&buf)
+				switch {
+				case errors.Is(err, errSkimNoMatch):
+					fmt.Println("exit no match")
+					return nil
+				case errors.Is(err, errSkimInterrupted):
+					fmt.Println("exit interrupted")
+					return nil
+				case err != nil:
+					return err
 				}
 
-				switch skInstr := strings.TrimSpace(outBuf.String()); skInstr {
+				switch skInstr {
 				case "claude-commit-llamax":
-					x := exec.Command("yag", "claude", "commit")
-					x.Stdout = os.Stdout
-					x.Stderr = os.Stderr
-					x.Stdin = os.Stdin
-					if err = x.Run(); err != nil {
+					if err = yag("claude", "commit"); err != nil {
 						return fmt.Errorf("yag claude commit: %w", err)
 					}
 				case "claude-commit":
-					x := exec.Command("yag", "claude", "commit", "--no-llama")
-					x.Stdout = os.Stdout
-					x.Stderr = os.Stderr
-					x.Stdin = os.Stdin
-					if err = x.Run(); err != nil {
+					if err = yag("claude", "commit", "--no-llama"); err != nil {
 						return fmt.Errorf("yag claude commit: %w", err)
 					}
 				case "help":
@@ -84,20 +66,12 @@ func newSkimCommand() *cobra.Command {
 				case "done":
 					break INSTR_LOOP
 				case "tag-last-commit":
-					x := exec.Command("yag", "tag")
-					x.Stdout = os.Stdout
-					x.Stderr = os.Stderr
-					x.Stdin = os.Stdin
-					if err = x.Run(); err != nil {
-						return fmt.Errorf("yag claude commit: %w", err)
+					if err = yag("tag"); err != nil {
+						return fmt.Errorf("yag tag: %w", err)
 					}
 
 				default:
-					c = exec.Command("git", "add", skInstr)
-					c.Stdout = os.Stdout
-					c.Stderr = os.Stderr
-					err = c.Run()
-					if err != nil {
+					if err = repo.Add(skInstr); err != nil {
 						return err
 					}
 				}
@@ -111,3 +85,36 @@ func newSkimCommand() *cobra.Command {
 	return cmd
 
 }
+
+var (
+	errSkimNoMatch     = errors.New("sk: no match")
+	errSkimInterrupted = errors.New("sk: interrupted")
+)
+
+// hasSkim tells if sk is installed.
+func hasSkim() bool {
+	_, err := exec.LookPath("sk")
+	return err == nil
+}
+
+// skim runs sk with args on the lines of in and returns the selected line.
+func skim(in io.Reader, args ...string) (string, error) {
+	c := exec.Command("sk", args...)
+	c.Stdin = in
+	var outBuf bytes.Buffer
+	c.Stdout = &outBuf
+	c.Stderr = os.Stderr
+	err := c.Run()
+	if exiterr, ok := err.(*exec.ExitError); ok {
+		switch exiterr.ExitCode() {
+		case 1:
+			return "", errSkimNoMatch
+		case 130: // C-C or ESC
+			return "", errSkimInterrupted
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(outBuf.String()), nil
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readSSE calls fn for every server-sent event read from r, as specified by
+// the text/event-stream format: "event" and "data" fields, comments
+// starting with a colon and events ended by a blank line.
+func readSSE(r io.Reader, fn func(event string, data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var (
+		event string
+		data  bytes.Buffer
+		seen  bool
+	)
+	dispatch := func() error {
+		if !seen {
+			return nil
+		}
+		if event == "" {
+			event = "message"
+		}
+		err := fn(event, data.Bytes())
+		event, seen = "", false
+		data.Reset()
+		return err
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+			seen = true
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			seen = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read event stream: %w", err)
+	}
+	return dispatch()
+}
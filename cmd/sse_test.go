@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func Test_readSSE(t *testing.T) {
+	in := ": comment\n" +
+		"event: ping\n" +
+		"data: {}\n" +
+		"\n" +
+		"data: line 1\n" +
+		"data: line 2\n" +
+		"\n" +
+		"event: last\n" +
+		"data:no space"
+	var got []string
+	err := readSSE(strings.NewReader(in), func(event string, data []byte) error {
+		got = append(got, event+"="+string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ping={}", "message=line 1\nline 2", "last=no space"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func claudeSSE(typ, data string) string {
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", typ, data)
+}
+
+func Test_anthropicGenerator_stream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(readBody(t, r), `"stream":true`) {
+			t.Error("stream not requested")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, claudeSSE("message_start", `{"type":"message_start","message":{"id":"msg_1","model":"claude-test","content":[]}}`))
+		fmt.Fprint(w, claudeSSE("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`))
+		fmt.Fprint(w, claudeSSE("ping", `{"type": "ping"}`))
+		for _, tok := range []string{"Stream ", "claude ", "responses"} {
+			fmt.Fprint(w, claudeSSE("content_block_delta", fmt.Sprintf(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":%q}}`, tok)))
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, claudeSSE("content_block_stop", `{"type":"content_block_stop","index":0}`))
+		fmt.Fprint(w, claudeSSE("message_delta", `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":3}}`))
+		fmt.Fprint(w, claudeSSE("message_stop", `{"type":"message_stop"}`))
+	}))
+	defer srv.Close()
+
+	var stream bytes.Buffer
+	g := anthropicGenerator{baseURL: srv.URL, apiKey: "sk-test", model: "claude-test", debug: zap.NewNop()}
+	req := genRequest{prompt: "diff", maxTokens: 256}
+	req.stream = &stream
+	got, err := g.Generate(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Stream claude responses" || stream.String() != got {
+		t.Errorf("got %q, streamed %q", got, stream.String())
+	}
+}
+
+func Test_anthropicGenerator_streamError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, claudeSSE("error", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	}))
+	defer srv.Close()
+
+	g := anthropicGenerator{baseURL: srv.URL, apiKey: "sk-test", model: "claude-test", debug: zap.NewNop()}
+	req := genRequest{prompt: "diff", maxTokens: 256}
+	req.stream = &bytes.Buffer{}
+	_, err := g.Generate(context.Background(), req)
+	var apiErr claudeAPIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "overloaded_error" {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func Test_anthropicGenerator_streamCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, claudeSSE("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Half"}}`))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stream bytes.Buffer
+	g := anthropicGenerator{baseURL: srv.URL, apiKey: "sk-test", model: "claude-test", debug: zap.NewNop()}
+	req := genRequest{prompt: "diff", maxTokens: 256}
+	req.stream = writerFunc(func(p []byte) (int, error) {
+		cancel() // Ctrl-C after the first token
+		return stream.Write(p)
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := g.Generate(ctx, req)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not aborted on cancel")
+	}
+	if stream.String() != "Half" {
+		t.Errorf("streamed %q", stream.String())
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func readBody(t *testing.T, r *http.Request) string {
+	t.Helper()
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(r.Body); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
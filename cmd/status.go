@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// statusKind is the leading character of a porcelain v2 entry.
+type statusKind byte
+
+const (
+	statusOrdinary  statusKind = '1'
+	statusRenamed   statusKind = '2'
+	statusUnmerged  statusKind = 'u'
+	statusUntracked statusKind = '?'
+	statusIgnored   statusKind = '!'
+)
+
+// fstat is one file entry of git status --porcelain=v2.
+//
+// staged and unstaged hold the X and Y status letters, '.' meaning
+// unmodified. Untracked and ignored entries repeat their kind character in
+// both fields, as the short format does.
+type fstat struct {
+	kind     statusKind
+	staged   byte
+	unstaged byte
+	sub      string   // submodule state, "N..." when not a submodule
+	modes    []string // octal file modes: HEAD, index, worktree (stages 1-3 and worktree when unmerged)
+	hashes   []string // object names: HEAD, index (stages 1-3 when unmerged)
+	score    string   // rename or copy score such as R100 or C75
+	path     string
+	origPath string // source path of a rename or copy
+}
+
+func (fs fstat) untrackedNewFile() bool {
+	return fs.kind == statusOrdinary && fs.staged == 'A' && fs.unstaged == '.'
+}
+
+func (fs fstat) modified() bool {
+	if fs.kind != statusOrdinary && fs.kind != statusRenamed {
+		return false
+	}
+	return fs.unstaged == 'M' || fs.staged == 'M'
+}
+
+func (fs fstat) untracked() bool {
+	return fs.kind == statusUntracked
+}
+
+func (fs fstat) ignored() bool {
+	return fs.kind == statusIgnored
+}
+
+func (fs fstat) unmerged() bool {
+	return fs.kind == statusUnmerged
+}
+
+func (fs fstat) renamed() bool {
+	return fs.kind == statusRenamed && fs.staged == 'R'
+}
+
+func (fs fstat) copied() bool {
+	return fs.kind == statusRenamed && fs.staged == 'C'
+}
+
+func (fs fstat) isStaged() bool {
+	switch fs.kind {
+	case statusOrdinary, statusRenamed:
+		return fs.staged != '.'
+	}
+	return false
+}
+
+// similarity returns the percentage of a rename or copy score.
+func (fs fstat) similarity() int {
+	if len(fs.score) < 2 {
+		return 0
+	}
+	n, _ := strconv.Atoi(fs.score[1:])
+	return n
+}
+
+// branchStatus holds the "# branch.*" headers.
+type branchStatus struct {
+	oid      string // "(initial)" before the first commit
+	head     string // "(detached)" when HEAD is detached
+	upstream string
+	hasAB    bool
+	ahead    int
+	behind   int
+}
+
+func (b branchStatus) detached() bool {
+	return b.head == "(detached)"
+}
+
+type repoStatus struct {
+	branch branchStatus
+	files  []fstat
+}
+
+// parseStatus parses the output of git status --porcelain=v2 -z [--branch].
+func parseStatus(out []byte) (repoStatus, error) {
+	var s repoStatus
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i < len(fields); i++ {
+		line := string(fields[i])
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case '#':
+			if err := s.branch.parseHeader(line); err != nil {
+				return s, err
+			}
+		case '1':
+			// 1 <XY> <sub> <mH> <mI> <mW> <hH> <hI> <path>
+			p := strings.SplitN(line, " ", 9)
+			if len(p) != 9 || len(p[1]) != 2 {
+				return s, fmt.Errorf("malformed ordinary entry %q", line)
+			}
+			s.files = append(s.files, fstat{
+				kind:     statusOrdinary,
+				staged:   p[1][0],
+				unstaged: p[1][1],
+				sub:      p[2],
+				modes:    p[3:6],
+				hashes:   p[6:8],
+				path:     p[8],
+			})
+		case '2':
+			// 2 <XY> <sub> <mH> <mI> <mW> <hH> <hI> <X><score> <path>NUL<origPath>
+			p := strings.SplitN(line, " ", 10)
+			if len(p) != 10 || len(p[1]) != 2 {
+				return s, fmt.Errorf("malformed rename entry %q", line)
+			}
+			if i+1 >= len(fields) || len(fields[i+1]) == 0 {
+				return s, fmt.Errorf("rename entry %q: missing original path", line)
+			}
+			i++
+			s.files = append(s.files, fstat{
+				kind:     statusRenamed,
+				staged:   p[1][0],
+				unstaged: p[1][1],
+				sub:      p[2],
+				modes:    p[3:6],
+				hashes:   p[6:8],
+				score:    p[8],
+				path:     p[9],
+				origPath: string(fields[i]),
+			})
+		case 'u':
+			// u <XY> <sub> <m1> <m2> <m3> <mW> <h1> <h2> <h3> <path>
+			p := strings.SplitN(line, " ", 11)
+			if len(p) != 11 || len(p[1]) != 2 {
+				return s, fmt.Errorf("malformed unmerged entry %q", line)
+			}
+			s.files = append(s.files, fstat{
+				kind:     statusUnmerged,
+				staged:   p[1][0],
+				unstaged: p[1][1],
+				sub:      p[2],
+				modes:    p[3:7],
+				hashes:   p[7:10],
+				path:     p[10],
+			})
+		case '?', '!':
+			if len(line) < 3 {
+				return s, fmt.Errorf("malformed entry %q", line)
+			}
+			s.files = append(s.files, fstat{
+				kind:     statusKind(line[0]),
+				staged:   line[0],
+				unstaged: line[0],
+				path:     line[2:],
+			})
+		default:
+			return s, fmt.Errorf("unknown status entry %q", line)
+		}
+	}
+	return s, nil
+}
+
+func (b *branchStatus) parseHeader(line string) error {
+	key, value, _ := strings.Cut(strings.TrimPrefix(line, "# "), " ")
+	switch key {
+	case "branch.oid":
+		b.oid = value
+	case "branch.head":
+		b.head = value
+	case "branch.upstream":
+		b.upstream = value
+	case "branch.ab":
+		if _, err := fmt.Sscanf(value, "+%d -%d", &b.ahead, &b.behind); err != nil {
+			return fmt.Errorf("malformed header %q: %w", line, err)
+		}
+		b.hasAB = true
+	}
+	// Unknown headers (stash count, ...) are ignored as git documents it.
+	return nil
+}
+
+// relToPrefix turns a repository relative path into a path relative to the
+// prefix directory (as given by git rev-parse --show-prefix).
+func relToPrefix(prefix, path string) string {
+	if prefix == "" {
+		return path
+	}
+	dir := strings.HasSuffix(path, "/")
+	rel, err := filepath.Rel(filepath.FromSlash(prefix), filepath.FromSlash(path))
+	if err != nil {
+		return path
+	}
+	if dir {
+		rel += string(os.PathSeparator)
+	}
+	return rel
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_parseStatus(t *testing.T) {
+	out := strings.Join([]string{
+		"# branch.oid 4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+		"# branch.head feat/PROJ-12-status",
+		"# branch.upstream origin/feat/PROJ-12-status",
+		"# branch.ab +2 -1",
+		"1 M. N... 100644 100644 100644 aaaa bbbb cmd/root.go",
+		"1 .M N... 100644 100644 100644 aaaa aaaa with space.txt",
+		"1 A. N... 000000 100644 100644 0000 cccc nouveau_été.md",
+		"2 R. N... 100644 100644 100644 dddd dddd R87 cmd/status.go",
+		"cmd/stat.go",
+		"u UU N... 100644 100644 100644 100644 eeee ffff 1111 go.sum",
+		"? untracked dir/",
+		"! ignored.log",
+		"",
+	}, "\x00")
+
+	s, err := parseStatus([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := s.branch
+	if b.head != "feat/PROJ-12-status" || b.upstream != "origin/feat/PROJ-12-status" {
+		t.Errorf("branch = %+v", b)
+	}
+	if !b.hasAB || b.ahead != 2 || b.behind != 1 {
+		t.Errorf("ahead/behind = %v +%d -%d", b.hasAB, b.ahead, b.behind)
+	}
+
+	tests := []struct {
+		path       string
+		origPath   string
+		staged     bool
+		modified   bool
+		newFile    bool
+		untracked  bool
+		unmerged   bool
+		renamed    bool
+		ignored    bool
+		similarity int
+	}{
+		{path: "cmd/root.go", staged: true, modified: true},
+		{path: "with space.txt", modified: true},
+		{path: "nouveau_été.md", staged: true, newFile: true},
+		{path: "cmd/status.go", origPath: "cmd/stat.go", staged: true, renamed: true, similarity: 87},
+		{path: "go.sum", unmerged: true},
+		{path: "untracked dir/", untracked: true},
+		{path: "ignored.log", ignored: true},
+	}
+	if len(s.files) != len(tests) {
+		t.Fatalf("got %d entries, want %d: %+v", len(s.files), len(tests), s.files)
+	}
+	for i, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			f := s.files[i]
+			if f.path != tt.path || f.origPath != tt.origPath {
+				t.Errorf("path = %q <- %q, want %q <- %q", f.path, f.origPath, tt.path, tt.origPath)
+			}
+			if f.isStaged() != tt.staged {
+				t.Errorf("isStaged() = %v", f.isStaged())
+			}
+			if f.modified() != tt.modified {
+				t.Errorf("modified() = %v", f.modified())
+			}
+			if f.untrackedNewFile() != tt.newFile {
+				t.Errorf("untrackedNewFile() = %v", f.untrackedNewFile())
+			}
+			if f.untracked() != tt.untracked {
+				t.Errorf("untracked() = %v", f.untracked())
+			}
+			if f.unmerged() != tt.unmerged {
+				t.Errorf("unmerged() = %v", f.unmerged())
+			}
+			if f.renamed() != tt.renamed {
+				t.Errorf("renamed() = %v", f.renamed())
+			}
+			if f.ignored() != tt.ignored {
+				t.Errorf("ignored() = %v", f.ignored())
+			}
+			if f.similarity() != tt.similarity {
+				t.Errorf("similarity() = %d", f.similarity())
+			}
+		})
+	}
+}
+
+func Test_parseStatus_malformed(t *testing.T) {
+	for _, out := range []string{
+		"1 M. N... 100644",
+		"2 R. N... 100644 100644 100644 dddd dddd R87 new",
+		"# branch.ab 2 1",
+		"x what",
+	} {
+		if _, err := parseStatus([]byte(out + "\x00")); err == nil {
+			t.Errorf("parseStatus(%q) expected an error", out)
+		}
+	}
+}
+
+func Test_relToPrefix(t *testing.T) {
+	tests := []struct{ prefix, path, want string }{
+		{"", "a/b.go", "a/b.go"},
+		{"a/", "a/b.go", "b.go"},
+		{"a/", "c/d.go", "../c/d.go"},
+		{"a/", "a/dir/", "dir/"},
+	}
+	for _, tt := range tests {
+		if got := relToPrefix(tt.prefix, tt.path); got != tt.want {
+			t.Errorf("relToPrefix(%q, %q) = %q, want %q", tt.prefix, tt.path, got, tt.want)
+		}
+	}
+}
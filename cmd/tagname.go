@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Default tag templates: the top directory, "root" at the root, the ticket
+// of the branch, the time and the last one or two directories below the
+// top one, like cmd.dev-PROJ-12-202501021504.05.sub.
+const (
+	defaultTagName = `{{.Part1}}.dev-{{with .Ticket}}{{.}}-{{end}}{{.Now.Format "` + tagCodeLayout + `"}}{{with .Part2}}.{{.}}{{end}}`
+	defaultTagLitt = `{{.Part1}}.dev-{{with .Ticket}}{{.}}-{{end}}{{.Now.Format "` + tagLittLayout + `"}}{{with .Part2}}.{{.}}{{end}}`
+)
+
+// tagCounterFile keeps the last counter value, in the git directory.
+var tagCounterFile = filepath.Join("yag", "tag-counter")
+
+// tagData is what the tag templates see.
+type tagData struct {
+	Path    []string  // directories from the root of the repository to the current one
+	Part1   string    // first directory of Path, "root" at the root
+	Part2   string    // last one or two directories of Path below Part1, dot joined
+	Branch  string    // current branch, empty when detached
+	Ticket  string    // ticket id found in the branch name
+	SHA     string    // short hash of HEAD, empty before the first commit
+	User    string    // login name
+	Now     time.Time // local time
+	UTC     time.Time
+	Counter int // next value of the repository counter, kept when the tag is made
+}
+
+var tagFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// replace takes the string last, to be piped: {{.Branch | replace "/" "-"}}
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+}
+
+func parseTagTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(tagFuncs).Option("missingkey=error").Parse(text)
+}
+
+// sampleTagData checks the templates of the configuration.
+var sampleTagData = tagData{
+	Path:    []string{"cmd", "sub"},
+	Part1:   "cmd",
+	Part2:   "sub",
+	Branch:  "feat/PROJ-12-login",
+	Ticket:  "PROJ-12",
+	SHA:     "1a2b3c4",
+	User:    "yag",
+	Now:     time.Date(2025, 1, 2, 15, 4, 5, 0, time.Local),
+	UTC:     time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC),
+	Counter: 1,
+}
+
+// renderTag executes a tag template and checks the result is a valid ref
+// name.
+func renderTag(key, text string, data tagData) (string, error) {
+	t, err := parseTagTemplate(key, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err = t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	tag := b.String()
+	if err = checkRefFormat(tag); err != nil {
+		return "", fmt.Errorf("renders %q: %w", tag, err)
+	}
+	return tag, nil
+}
+
+// tagNamer names the timestamp tags of the current location.
+type tagNamer struct {
+	repo Repo
+	cfg  tagConfig
+	now  func() time.Time // time.Now when nil
+}
+
+// name renders the tag. keep advances the counter when the template uses
+// it, previews leave it.
+func (n tagNamer) name(litt bool, ticket string, keep bool) (string, error) {
+	key, text := "tag.name", n.cfg.Name
+	if litt {
+		key, text = "tag.litt", n.cfg.Litt
+	}
+	if text == "" {
+		text = map[bool]string{false: defaultTagName, true: defaultTagLitt}[litt]
+	}
+	data, err := n.data(ticket)
+	if err != nil {
+		return "", err
+	}
+	counter := strings.Contains(text, ".Counter")
+	var counterFile string
+	if counter {
+		gitDir, err := n.repo.GitDir()
+		if err != nil {
+			return "", err
+		}
+		counterFile = filepath.Join(gitDir, tagCounterFile)
+		if data.Counter, err = readCounter(counterFile); err != nil {
+			return "", err
+		}
+		data.Counter++
+	}
+	tag, err := renderTag(key, text, data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	if counter && keep {
+		if err = writeCounter(counterFile, data.Counter); err != nil {
+			return "", err
+		}
+	}
+	return tag, nil
+}
+
+func (n tagNamer) data(ticket string) (tagData, error) {
+	now := time.Now
+	if n.now != nil {
+		now = n.now
+	}
+	t := now()
+	data := tagData{Ticket: ticket, Now: t.Local(), UTC: t.UTC(), User: loginName()}
+	gitroot, cd, err := gitRoot()
+	if err != nil {
+		return data, fmt.Errorf("gitroot: %w", err)
+	}
+	data.Path, data.Part1, data.Part2 = tagPath(gitroot, cd)
+	if s, err := n.repo.Status(); err == nil {
+		if !s.branch.detached() {
+			data.Branch = s.branch.head
+		}
+		if s.branch.oid != "(initial)" && len(s.branch.oid) >= 7 {
+			data.SHA = s.branch.oid[:7]
+		}
+	}
+	return data, nil
+}
+
+// tagPath splits the current directory below the root into the path
+// segments and the two parts of the default tag.
+func tagPath(gitroot, cd string) (path []string, part1, part2 string) {
+	cdpath := strings.Split(cd, string(os.PathSeparator))
+	rootpath := strings.Split(gitroot, string(os.PathSeparator))
+	delta := len(cdpath) - len(rootpath)
+	if delta <= 0 { // we are in git root directory
+		return nil, "root", ""
+	}
+	path = cdpath[len(rootpath):]
+	part1 = path[0] // root of sub directory of the root
+	if delta >= 2 {
+		l := 2
+		if delta == 2 {
+			l = 1
+		}
+		part2 = strings.Join(cdpath[len(cdpath)-l:], ".")
+	}
+	return path, part1, part2
+}
+
+func loginName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func readCounter(name string) (int, error) {
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return n, nil
+}
+
+func writeCounter(name string, n int) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, []byte(strconv.Itoa(n)+"\n"), 0644)
+}
+
+// checkRefFormat applies the rules of git check-ref-format to a tag name.
+func checkRefFormat(name string) error {
+	switch {
+	case name == "":
+		return errors.New("empty ref name")
+	case name == "@":
+		return errors.New(`ref name is "@"`)
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return errors.New("ref name begins or ends with /")
+	case strings.HasSuffix(name, "."):
+		return errors.New("ref name ends with .")
+	case strings.Contains(name, "//"):
+		return errors.New("ref name has consecutive slashes")
+	case strings.Contains(name, ".."):
+		return errors.New("ref name has ..")
+	case strings.Contains(name, "@{"):
+		return errors.New("ref name has @{")
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return fmt.Errorf("ref name has %q", r)
+		}
+	}
+	for _, c := range strings.Split(name, "/") {
+		if strings.HasPrefix(c, ".") {
+			return fmt.Errorf("ref name component %q begins with .", c)
+		}
+		if strings.HasSuffix(c, ".lock") {
+			return fmt.Errorf("ref name component %q ends with .lock", c)
+		}
+	}
+	return nil
+}
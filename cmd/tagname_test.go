@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_checkRefFormat(t *testing.T) {
+	git, _ := exec.LookPath("git")
+	for name, valid := range map[string]bool{
+		"cmd.dev-202501021504.05":     true,
+		"ann/cmd-PROJ-12-3":           true,
+		"root.dev-Thu.Jan.2.3.04PM":   true,
+		"":                            false,
+		"@":                           false,
+		"a..b":                        false,
+		"a b":                         false,
+		"a~1":                         false,
+		"a^":                          false,
+		"a:b":                         false,
+		"a?":                          false,
+		"a*":                          false,
+		"a[b":                         false,
+		`a\b`:                         false,
+		"a@{1}":                       false,
+		"a.":                          false,
+		"/a":                          false,
+		"a/":                          false,
+		"a//b":                        false,
+		".a":                          false,
+		"a/.b":                        false,
+		"a.lock":                      false,
+		"a.lock/b":                    false,
+		"a\x01":                       false,
+		"feat/PROJ-12-login.dev-1a2b": true,
+	} {
+		if err := checkRefFormat(name); (err == nil) != valid {
+			t.Errorf("checkRefFormat(%q) = %v, want valid %v", name, err, valid)
+		}
+		if git != "" && name != "" {
+			gitValid := exec.Command(git, "check-ref-format", "--allow-onelevel", name).Run() == nil
+			if gitValid != valid {
+				t.Errorf("git check-ref-format %q: valid %v, want %v", name, gitValid, valid)
+			}
+		}
+	}
+}
+
+func Test_tagNamer_name(t *testing.T) {
+	restore := gitRoot
+	defer func() { gitRoot = restore }()
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.Local)
+	tests := []struct {
+		name    string
+		cfg     tagConfig
+		litt    bool
+		cd      string
+		ticket  string
+		want    string
+		wantErr string
+	}{
+		{"root", tagConfig{}, false, "/src/yag", "", "root.dev-202501021504.05", ""},
+		{"sub", tagConfig{}, false, "/src/yag/cmd", "", "cmd.dev-202501021504.05", ""},
+		{"deep", tagConfig{}, false, "/src/yag/cmd/a/b/c", "PROJ-12", "cmd.dev-PROJ-12-202501021504.05.b.c", ""},
+		{"litt", tagConfig{}, true, "/src/yag/cmd/a", "", "cmd.dev-Thu.Jan.2.3.04PM.a", ""},
+		{"template", tagConfig{Name: `{{.User | lower}}/{{join .Path "-"}}-{{.Branch | replace "/" "-"}}-{{.SHA}}-{{.UTC.Format "0102"}}`}, false, "/src/yag/cmd/a", "", "cmd-a-feat-x-1a2b3c4-0102", ""},
+		{"invalid", tagConfig{Name: `{{.Part1}}..{{.Part2}}`}, false, "/src/yag/cmd/a", "", "", `tag.name: renders "cmd..a": ref name has ..`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitRoot = func() (string, string, error) { return "/src/yag", tt.cd, nil }
+			repo := newFakeRepo()
+			repo.status.branch = branchStatus{head: "feat/x", oid: "1a2b3c4d5e6f"}
+			got, err := tagNamer{repo: repo, cfg: tt.cfg, now: func() time.Time { return now }}.name(tt.litt, tt.ticket, false)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name == "template" {
+				got = strings.TrimPrefix(got, strings.ToLower(loginName())+"/")
+			}
+			if got != tt.want {
+				t.Errorf("name() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tagNamer_counter(t *testing.T) {
+	restore := gitRoot
+	defer func() { gitRoot = restore }()
+	gitRoot = func() (string, string, error) { return "/src/yag", "/src/yag", nil }
+	repo := newFakeRepo()
+	repo.root = t.TempDir()
+	n := tagNamer{repo: repo, cfg: tagConfig{Name: "build-{{.Counter}}"}}
+	for _, step := range []struct {
+		keep bool
+		want string
+	}{{false, "build-1"}, {true, "build-1"}, {true, "build-2"}, {false, "build-3"}} {
+		if got, err := n.name(false, "", step.keep); err != nil || got != step.want {
+			t.Errorf("name(keep %v) = %q, %v, want %q", step.keep, got, err, step.want)
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(repo.root, ".git", tagCounterFile))
+	if err != nil || string(b) != "2\n" {
+		t.Errorf("counter file = %q, %v", b, err)
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time layouts of the default tag templates. Litterate tags made before
+// the 3.04 fix run the hour and the unpadded minute together: the last two
+// digits are read as the minute, 115PM is 1:15 PM though it may be 11:05.
+const (
+	tagCodeLayout = "200601021504.05"
+	tagLittLayout = "Mon.Jan.2.3.04PM"
+)
+
+// yagTagPattern matches the tags of the default templates. The directory
+// is the shortest prefix before .dev-, a directory holding .dev- is read
+// wrong.
+var yagTagPattern = regexp.MustCompile(`^(?P<dir>.+?)\.dev-(?:(?P<ticket>.+?)-)?` +
+	`(?:(?P<code>\d{12}\.\d{2})|(?P<litt>(?P<weekday>Mon|Tue|Wed|Thu|Fri|Sat|Sun)\.(?P<month>[A-Z][a-z]{2})\.(?P<day>\d{1,2})\.(?P<clock>\d{1,2}\.\d{2}|\d{2,4})(?P<ampm>[AP]M)))` +
+	`(?:\.(?P<sub>.+))?$`)
+
+// yagTag is what a timestamp tag tells.
+type yagTag struct {
+	Name   string    `json:"tag"`
+	Dir    string    `json:"dir"`              // top directory, "root" at the root
+	Ticket string    `json:"ticket,omitempty"` // of the branch
+	Time   time.Time `json:"time"`
+	Litt   bool      `json:"litt,omitempty"` // litterate form, its year is guessed
+	Sub    string    `json:"sub,omitempty"`  // last one or two directories below dir, dot joined
+}
+
+// parseYagTag reads a tag made by the default tag.name or tag.litt
+// templates, not by configured ones. Litterate tags have no year, it is
+// the last one, up to now, when the date fell on the weekday of the tag.
+// Sub is kept as in the tag: with dotted directories, a.b.c may be a.b/c
+// or a/b.c.
+func parseYagTag(name string, now time.Time) (yagTag, error) {
+	m := yagTagPattern.FindStringSubmatch(name)
+	if m == nil || checkRefFormat(name) != nil {
+		return yagTag{}, fmt.Errorf("%q is not a yag timestamp tag of the default templates", name)
+	}
+	group := func(g string) string { return m[yagTagPattern.SubexpIndex(g)] }
+	t := yagTag{Name: name, Dir: group("dir"), Ticket: group("ticket"), Sub: group("sub")}
+	if code := group("code"); code != "" {
+		tm, err := time.ParseInLocation(tagCodeLayout, code, now.Location())
+		if err != nil {
+			return yagTag{}, fmt.Errorf("%q: %w", name, err)
+		}
+		t.Time = tm
+		return t, nil
+	}
+	t.Litt = true
+	month, err := time.Parse("Jan", group("month"))
+	if err != nil {
+		return yagTag{}, fmt.Errorf("%q: month: %w", name, err)
+	}
+	day, _ := strconv.Atoi(group("day"))
+	hh, mm, ok := strings.Cut(group("clock"), ".")
+	if !ok { // before the fix
+		split := max(len(hh)-2, 1)
+		hh, mm = hh[:split], hh[split:]
+	}
+	hour, _ := strconv.Atoi(hh)
+	minute, _ := strconv.Atoi(mm)
+	if hour < 1 || hour > 12 || minute > 59 {
+		return yagTag{}, fmt.Errorf("%q: no such time %d:%02d", name, hour, minute)
+	}
+	hour %= 12
+	if group("ampm") == "PM" {
+		hour += 12
+	}
+	for year := now.Year(); year > now.Year()-28; year-- { // weekdays repeat within 28 years
+		tm := time.Date(year, month.Month(), day, hour, minute, 0, 0, now.Location())
+		if tm.Day() != day || tm.After(now) || tm.Weekday().String()[:3] != group("weekday") {
+			continue
+		}
+		t.Time = tm
+		return t, nil
+	}
+	return yagTag{}, fmt.Errorf("%q: no %s %s %d in the last 28 years", name, group("weekday"), group("month"), day)
+}
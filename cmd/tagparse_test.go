@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseYagTag(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(y int, m time.Month, d, h, min, s int) time.Time {
+		return time.Date(y, m, d, h, min, s, 0, time.UTC)
+	}
+	tests := []struct {
+		tag     string
+		want    yagTag
+		wantErr string
+	}{
+		{"root.dev-202501021504.05", yagTag{Dir: "root", Time: at(2025, 1, 2, 15, 4, 5)}, ""},
+		{"cmd.dev-202501021504.05.b.c", yagTag{Dir: "cmd", Time: at(2025, 1, 2, 15, 4, 5), Sub: "b.c"}, ""},
+		{"docs.site.dev-202501021504.05.v1.2", yagTag{Dir: "docs.site", Time: at(2025, 1, 2, 15, 4, 5), Sub: "v1.2"}, ""},
+		{"cmd.dev-PROJ-12-202501021504.05.a", yagTag{Dir: "cmd", Ticket: "PROJ-12", Time: at(2025, 1, 2, 15, 4, 5), Sub: "a"}, ""},
+		{"cmd.dev-Thu.Jan.2.3.04PM.a", yagTag{Dir: "cmd", Time: at(2025, 1, 2, 15, 4, 0), Litt: true, Sub: "a"}, ""},
+		{"cmd.dev-Sat.Oct.17.1142PM", yagTag{Dir: "cmd", Time: at(2020, 10, 17, 23, 42, 0), Litt: true}, ""},
+		{"cmd.dev-Mon.Jan.6.15AM", yagTag{Dir: "cmd", Time: at(2025, 1, 6, 1, 5, 0), Litt: true}, ""},
+		{"cmd.dev-Thu.Jan.2.12.00AM", yagTag{Dir: "cmd", Time: at(2025, 1, 2, 0, 0, 0), Litt: true}, ""},
+		{"add parser cmd.dev-202501021504.05", yagTag{}, "is not a yag timestamp tag"},
+		{"v1.2.3", yagTag{}, `"v1.2.3" is not a yag timestamp tag of the default templates`},
+		{"cmd.dev-Thu.Jan.2.13.00PM", yagTag{}, "no such time 13:00"},
+		{"cmd.dev-Mon.Feb.30.3.04PM", yagTag{}, "no Mon Feb 30 in the last 28 years"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := parseYagTag(tt.tag, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Name = tt.tag
+			if got != tt.want {
+				t.Errorf("parseYagTag() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseYagTag_roundTrip(t *testing.T) {
+	restore := gitRoot
+	defer func() { gitRoot = restore }()
+	gitRoot = func() (string, string, error) { return "/src/yag", "/src/yag/cmd/a/b", nil }
+	made := time.Date(2025, 1, 2, 15, 4, 5, 0, time.Local)
+	for _, litt := range []bool{false, true} {
+		tag, err := tagNamer{repo: newFakeRepo(), now: func() time.Time { return made }}.name(litt, "PROJ-12", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseYagTag(tag, made.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := made
+		if litt {
+			want = made.Truncate(time.Minute)
+		}
+		if got.Dir != "cmd" || got.Ticket != "PROJ-12" || got.Sub != "a.b" || !got.Time.Equal(want) {
+			t.Errorf("parseYagTag(%q) = %+v", tag, got)
+		}
+	}
+}
+
+func Test_newTimestampListCommand(t *testing.T) {
+	repo := newFakeRepo()
+	repo.tags = []string{
+		"v1.0.0",
+		"cmd.dev-202501021504.05",
+		"test.dev-202501031000.00",
+		"cmd.dev-202501051200.00.sub",
+		"root.dev-202412311200.00",
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "" +
+			"cmd: 2 tag(s), last 2025-01-05 12:00\n" +
+			"  2025-01-05 12:00  cmd.dev-202501051200.00.sub  sub\n" +
+			"  2025-01-02 15:04  cmd.dev-202501021504.05\n" +
+			"test: 1 tag(s), last 2025-01-03 10:00\n" +
+			"  2025-01-03 10:00  test.dev-202501031000.00\n" +
+			"root: 1 tag(s), last 2024-12-31 12:00\n" +
+			"  2024-12-31 12:00  root.dev-202412311200.00\n"},
+		{[]string{"--dir", "test"}, "" +
+			"test: 1 tag(s), last 2025-01-03 10:00\n" +
+			"  2025-01-03 10:00  test.dev-202501031000.00\n"},
+		{[]string{"--since", "2025-01-03"}, "" +
+			"cmd: 1 tag(s), last 2025-01-05 12:00\n" +
+			"  2025-01-05 12:00  cmd.dev-202501051200.00.sub  sub\n" +
+			"test: 1 tag(s), last 2025-01-03 10:00\n" +
+			"  2025-01-03 10:00  test.dev-202501031000.00\n"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := newTimestampListCommand(repo, &out)
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("ls =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	cmd := newTimestampListCommand(repo, &out)
+	cmd.SetArgs([]string{"--json", "--dir", "cmd"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var groups []tagGroup
+	if err := json.Unmarshal(out.Bytes(), &groups); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0].Tags) != 2 || groups[0].Tags[0].Sub != "sub" {
+		t.Errorf("json = %s", out.String())
+	}
+}
+
+func Test_groupTags_skipped(t *testing.T) {
+	names := []string{"v1.0.0", "cmd.dev-sometime", "cmd.dev-202501021504.05"}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	all := func(yagTag) bool { return true }
+	for custom, want := range map[bool]int{false: 1, true: 2} {
+		groups, skipped := groupTags(names, now, custom, all)
+		if len(groups) != 1 || skipped != want {
+			t.Errorf("custom %v: %d group(s), %d skipped, want 1, %d", custom, len(groups), skipped, want)
+		}
+	}
+}
+
+func Test_parseSince(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	for v, want := range map[string]time.Time{
+		"2025-01-02":       time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+		"2025-01-02T15:04": time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC),
+		"36h":              now.Add(-36 * time.Hour),
+		"7d":               time.Date(2025, 1, 3, 12, 0, 0, 0, time.UTC),
+		"1w":               time.Date(2025, 1, 3, 12, 0, 0, 0, time.UTC),
+	} {
+		if got, err := parseSince(v, now); err != nil || !got.Equal(want) {
+			t.Errorf("parseSince(%q) = %s, %v, want %s", v, got, err, want)
+		}
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Error("parseSince(yesterday) did not fail")
+	}
+}
+
+func Test_newTimestampParseCommand(t *testing.T) {
+	var out bytes.Buffer
+	cmd := newTimestampParseCommand(&out)
+	cmd.SetArgs([]string{"cmd.dev-PROJ-12-202501021504.05.a"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	want := "tag     cmd.dev-PROJ-12-202501021504.05.a\ndir     cmd\nticket  PROJ-12\ntime    Thu 2025-01-02 15:04:05\nsub     a\n"
+	if out.String() != want {
+		t.Errorf("parse =\n%s\nwant\n%s", out.String(), want)
+	}
+}
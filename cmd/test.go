@@ -6,7 +6,7 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func newTestSubCommand(class string, isStaged bool, isClass func(fstat) bool) *cobra.Command {
+func newTestSubCommand(repo Repo, class string, isStaged bool, isClass func(fstat) bool) *cobra.Command {
 	staged := "unstaged"
 	if isStaged {
 		staged = "staged"
@@ -15,15 +15,15 @@ func newTestSubCommand(class string, isStaged bool, isClass func(fstat) bool) *c
 	return &cobra.Command{
 		Use: name,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			s, err := stat()
+			status, err := repo.Status()
 			if err != nil {
-				return fmt.Errorf("stat(): %w", err)
+				return fmt.Errorf("git status: %w", err)
 			}
-			for _, s := range s {
+			for _, s := range status.files {
 				if isStaged && s.isStaged() && isClass(s) {
 					fmt.Println(s.path)
 				}
-				if !isStaged && (!s.isStaged() || s.staged == '?') && isClass(s) {
+				if !isStaged && !s.isStaged() && isClass(s) {
 					fmt.Println(s.path)
 				}
 			}
@@ -32,17 +32,17 @@ func newTestSubCommand(class string, isStaged bool, isClass func(fstat) bool) *c
 	}
 }
 
-func newTestCommand() *cobra.Command {
+func newTestCommand(repo Repo) *cobra.Command {
 	cmd := &cobra.Command{
 		Use: "test",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return cmd.Help()
 		},
 	}
-	listChangedStaged := newTestSubCommand("changed", true, func(f fstat) bool { return f.modified() })
-	listUntrackedStaged := newTestSubCommand("untracked", true, func(f fstat) bool { return f.untrackedNewFile() })
-	listChangedUnstaged := newTestSubCommand("changed", false, func(f fstat) bool { return f.modified() })
-	listUntrackedUnstaged := newTestSubCommand("untracked", false, func(f fstat) bool { return f.untracked() })
+	listChangedStaged := newTestSubCommand(repo, "changed", true, func(f fstat) bool { return f.modified() })
+	listUntrackedStaged := newTestSubCommand(repo, "untracked", true, func(f fstat) bool { return f.untrackedNewFile() })
+	listChangedUnstaged := newTestSubCommand(repo, "changed", false, func(f fstat) bool { return f.modified() })
+	listUntrackedUnstaged := newTestSubCommand(repo, "untracked", false, func(f fstat) bool { return f.untracked() })
 	cmd.AddCommand(
 		listChangedStaged,
 		listUntrackedStaged,
@@ -1,28 +1,216 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
-func newTimestampCodeCommand() *cobra.Command {
+	"github.com/spf13/cobra"
+)
+
+func newTimestampCodeCommand(repo Repo, out io.Writer) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "timestamp",
 		Aliases: []string{"ts"},
-		Short:   "make a timestamped tag for current location",
+		Short:   "make a timestamped tag for current location, named by the tag.name template",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return tstampFormat{}.print()
+			return printTimestamp(repo, cmd, out, false)
 		},
 	}
 	return cmd
 }
 
-func newTimestampLitterateCommand() *cobra.Command {
+func newTimestampLitterateCommand(repo Repo, out io.Writer) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "litt",
-		Short: "litterate version of timestamp command",
+		Short: "litterate version of timestamp command, named by the tag.litt template",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printTimestamp(repo, cmd, out, true)
+		},
+	}
+	return cmd
+}
+
+func printTimestamp(repo Repo, cmd *cobra.Command, out io.Writer, litt bool) error {
+	cfg, err := loadConfig(repo, cmd)
+	if err != nil {
+		return err
+	}
+	tag, err := tagNamer{repo: repo, cfg: cfg.Tag}.name(litt, branchTicket(repo, cfg.Issues), true)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, tag)
+	return err
+}
+
+func newTimestampParseCommand(out io.Writer) *cobra.Command {
+	var jsonOpt *bool
+	cmd := &cobra.Command{
+		Use:   "parse tag...",
+		Short: "tell the directory, ticket, time and sub path of timestamp tags",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var tags []yagTag
+			for _, arg := range args {
+				t, err := parseYagTag(arg, time.Now())
+				if err != nil {
+					return err
+				}
+				tags = append(tags, t)
+			}
+			if *jsonOpt {
+				return writeJSON(out, tags)
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			for i, t := range tags {
+				if i > 0 {
+					fmt.Fprintln(tw)
+				}
+				fmt.Fprintf(tw, "tag\t%s\ndir\t%s\n", t.Name, t.Dir)
+				if t.Ticket != "" {
+					fmt.Fprintf(tw, "ticket\t%s\n", t.Ticket)
+				}
+				fmt.Fprintf(tw, "time\t%s\n", t.Time.Format("Mon 2006-01-02 15:04:05"))
+				if t.Sub != "" {
+					fmt.Fprintf(tw, "sub\t%s\n", t.Sub)
+				}
+			}
+			return tw.Flush()
+		},
+	}
+	jsonOpt = cmd.Flags().Bool("json", false, "print a json array")
+	return cmd
+}
+
+// tagGroup are the timestamp tags of a directory, the most recent first.
+type tagGroup struct {
+	Dir  string    `json:"dir"`
+	Last time.Time `json:"last"`
+	Tags []yagTag  `json:"tags"`
+}
+
+func newTimestampListCommand(repo Repo, out io.Writer) *cobra.Command {
+	var sinceOpt, dirOpt *string
+	var jsonOpt *bool
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "list the timestamp tags by directory, the last touched first",
+		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return tstampFormat{
-				litt: true,
-			}.print()
+			cfg, err := loadConfig(repo, cmd)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			var since time.Time
+			if *sinceOpt != "" {
+				if since, err = parseSince(*sinceOpt, now); err != nil {
+					return err
+				}
+			}
+			names, err := repo.Tags()
+			if err != nil {
+				return err
+			}
+			custom := cfg.Tag.Name != defaultTagName || cfg.Tag.Litt != defaultTagLitt
+			groups, skipped := groupTags(names, now, custom, func(t yagTag) bool {
+				return !t.Time.Before(since) && (*dirOpt == "" || t.Dir == *dirOpt)
+			})
+			if skipped > 0 {
+				warn("%d tag(s) skipped, ts ls only reads the tags of the default tag.name and tag.litt templates", skipped)
+			}
+			if *jsonOpt {
+				return writeJSON(out, groups)
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			for _, g := range groups {
+				fmt.Fprintf(tw, "%s: %d tag(s), last %s\n", g.Dir, len(g.Tags), g.Last.Format("2006-01-02 15:04"))
+				for _, t := range g.Tags {
+					line := "  " + t.Time.Format("2006-01-02 15:04") + "\t" + t.Name
+					if extra := strings.TrimSpace(t.Ticket + " " + t.Sub); extra != "" {
+						line += "\t" + extra
+					}
+					fmt.Fprintln(tw, line)
+				}
+			}
+			return tw.Flush()
 		},
 	}
+	sinceOpt = cmd.Flags().String("since", "", "only the tags since a date (2006-01-02) or a duration ago (36h, 7d, 2w)")
+	dirOpt = cmd.Flags().String("dir", "", "only the tags of a directory")
+	jsonOpt = cmd.Flags().Bool("json", false, "print a json array of the directories and their tags")
 	return cmd
 }
+
+// groupTags groups the timestamp tags kept by keep by directory, the other
+// tags are left out. The directories last touched come first. skipped
+// counts the tags not read: those with .dev- in their name, or all of them
+// when the templates are custom, the others being release tags and such.
+func groupTags(names []string, now time.Time, custom bool, keep func(yagTag) bool) (groups []tagGroup, skipped int) {
+	byDir := map[string]*tagGroup{}
+	for _, name := range names {
+		t, err := parseYagTag(name, now)
+		if err != nil {
+			if custom || strings.Contains(name, ".dev-") {
+				skipped++
+			}
+			continue
+		}
+		if !keep(t) {
+			continue
+		}
+		g, ok := byDir[t.Dir]
+		if !ok {
+			g = &tagGroup{Dir: t.Dir}
+			byDir[t.Dir] = g
+		}
+		g.Tags = append(g.Tags, t)
+		if t.Time.After(g.Last) {
+			g.Last = t.Time
+		}
+	}
+	groups = make([]tagGroup, 0, len(byDir))
+	for _, g := range byDir {
+		slices.SortFunc(g.Tags, func(a, b yagTag) int { return b.Time.Compare(a.Time) })
+		groups = append(groups, *g)
+	}
+	slices.SortFunc(groups, func(a, b tagGroup) int {
+		if c := b.Last.Compare(a.Last); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Dir, b.Dir)
+	})
+	return groups, skipped
+}
+
+// parseSince reads a date, or a duration before now with d and w units
+// for days and weeks.
+func parseSince(v string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, v, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if unit, ok := units[v[len(v)-1]]; ok {
+		if n, err := strconv.Atoi(v[:len(v)-1]); err == nil && n >= 0 {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("--since %q, want a date like 2006-01-02 or a duration like 36h, 7d or 2w", v)
+}
+
+func writeJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// trailersConfig is the "trailers" section of the configuration: the
+// trailers the commit commands add to the generated messages, after the
+// pipeline.trailers lines.
+type trailersConfig struct {
+	Signoff bool     `yaml:"signoff"` // Signed-off-by from user.name and user.email
+	Refs    bool     `yaml:"refs"`    // Refs with the ticket id of the branch
+	Roster  []string `yaml:"roster"`  // co-authors to pick from, "Name <email>"
+}
+
+const (
+	trailerSignoff  = "Signed-off-by"
+	trailerRefs     = "Refs"
+	trailerCoAuthor = "Co-authored-by"
+	// pickCoAuthors is the --co-author value asking to pick from the roster.
+	pickCoAuthors = "?"
+)
+
+// nameAddr is a "Name <email>" identity.
+var nameAddr = regexp.MustCompile(`^[^<>]*[^<>\s] <[^<>\s@]+@[^<>\s]+>$`)
+
+// commitTrailers are the trailers of the commit: sign-off, the ticket of
+// the branch and the co-authors, already resolved.
+func commitTrailers(repo Repo, cfg trailersConfig, ticket string, coAuthors []string) ([]string, error) {
+	var trailers []string
+	if cfg.Signoff {
+		name, err := repo.Config("user.name")
+		if err != nil {
+			return nil, err
+		}
+		email, err := repo.Config("user.email")
+		if err != nil {
+			return nil, err
+		}
+		if name == "" || email == "" {
+			return nil, errors.New("Signed-off-by needs git user.name and user.email")
+		}
+		trailers = append(trailers, fmt.Sprintf("%s: %s <%s>", trailerSignoff, name, email))
+	}
+	if cfg.Refs && ticket != "" {
+		trailers = append(trailers, trailerRefs+": "+ticket)
+	}
+	for _, c := range coAuthors {
+		trailers = append(trailers, trailerCoAuthor+": "+c)
+	}
+	return trailers, nil
+}
+
+// commitTrailers resolves the --co-author names, then returns the trailers
+// of the commit.
+func (f commitFlow) commitTrailers(cfg trailersConfig, names []string) ([]string, error) {
+	var coAuthors []string
+	for _, name := range names {
+		if name == pickCoAuthors {
+			picked, err := f.pickCoAuthors(cfg.Roster)
+			if err != nil {
+				return nil, err
+			}
+			coAuthors = append(coAuthors, picked...)
+			continue
+		}
+		c, err := matchCoAuthor(cfg.Roster, name)
+		if err != nil {
+			return nil, err
+		}
+		coAuthors = append(coAuthors, c)
+	}
+	trailers, err := commitTrailers(f.repo, cfg, f.context.Ticket, coAuthors)
+	if err != nil {
+		return nil, err
+	}
+	if len(trailers) > 0 && !slices.Contains(f.pipeline.stages(), stageTrailers) {
+		warn("no trailers stage in pipeline.stages, %s not added", strings.Join(trailers, ", "))
+	}
+	return trailers, nil
+}
+
+// matchCoAuthor finds name in the roster, by any part of the name or email.
+// A "Name <email>" missing from the roster is taken as is.
+func matchCoAuthor(roster []string, name string) (string, error) {
+	if nameAddr.MatchString(name) {
+		return name, nil
+	}
+	var found []string
+	for _, c := range roster {
+		if strings.Contains(strings.ToLower(c), strings.ToLower(name)) {
+			found = append(found, c)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("co-author %q is not in trailers.roster, give \"Name <email>\"", name)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("co-author %q is ambiguous: %s", name, strings.Join(found, ", "))
+}
+
+// pickCoAuthors lets the user choose co-authors in the roster, with sk when
+// it is installed or from a numbered list otherwise.
+func (f commitFlow) pickCoAuthors(roster []string) ([]string, error) {
+	if len(roster) == 0 {
+		return nil, errors.New("no co-author to pick, trailers.roster is empty")
+	}
+	if !f.interactive {
+		return nil, errors.New("--co-author ? needs a terminal to pick co-authors")
+	}
+	if f.useSkim {
+		out, err := skim(strings.NewReader(strings.Join(roster, "\n")+"\n"), "--multi", "--prompt", "co-authors> ")
+		if errors.Is(err, errSkimNoMatch) || errors.Is(err, errSkimInterrupted) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if out == "" {
+			return nil, nil
+		}
+		return strings.Split(out, "\n"), nil
+	}
+	for i, c := range roster {
+		fmt.Fprintf(f.out, "%2d. %s\n", i+1, c)
+	}
+	for {
+		answer, err := f.readLine(fmt.Sprintf("co-authors [1-%d, separated by spaces] (none): ", len(roster)))
+		if err != nil {
+			return nil, err
+		}
+		picked, ok := pickNumbers(roster, answer)
+		if ok {
+			return picked, nil
+		}
+	}
+}
+
+// pickNumbers returns the roster entries numbered in answer, false when a
+// number is out of range.
+func pickNumbers(roster []string, answer string) ([]string, bool) {
+	var picked []string
+	for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ' ' || r == ',' }) {
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 1 || i > len(roster) {
+			return nil, false
+		}
+		if !slices.Contains(picked, roster[i-1]) {
+			picked = append(picked, roster[i-1])
+		}
+	}
+	return picked, true
+}
+
+// mergeTrailers adds the trailers to the trailer block of msg the way git
+// interpret-trailers --if-exists addIfDifferent --where end does: a
+// trailer with the same token, in any case, and the same value is not
+// added again, the others go at the end of the block, in a new paragraph
+// when msg has none. It returns the trailers added.
+func mergeTrailers(msg string, trailers []string) (string, []string) {
+	paragraphs := strings.Split(strings.TrimRight(msg, "\n"), "\n\n")
+	var existing [][2]string
+	if len(paragraphs) > 1 {
+		existing, _ = parseFooters(paragraphs[len(paragraphs)-1])
+	}
+	var added []string
+	for _, t := range trailers {
+		m := trailerLine.FindStringSubmatch(t)
+		if m == nil {
+			continue
+		}
+		token, value := m[1], strings.TrimSpace(m[2])
+		if slices.ContainsFunc(existing, func(e [2]string) bool {
+			return strings.EqualFold(e[0], token) && strings.TrimSpace(e[1]) == value
+		}) {
+			continue
+		}
+		msg = appendFooter(msg, token, value)
+		existing = append(existing, [2]string{token, value})
+		added = append(added, token+": "+value)
+	}
+	return msg, added
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_mergeTrailers(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		trailers []string
+		want     string
+		added    []string
+	}{
+		{"new block", "add parser\n\nParse porcelain v2.\n", []string{"Refs: PROJ-1"}, "add parser\n\nParse porcelain v2.\n\nRefs: PROJ-1\n", []string{"Refs: PROJ-1"}},
+		{"subject only", "Refs: parser\n", []string{"Refs: PROJ-1"}, "Refs: parser\n\nRefs: PROJ-1\n", []string{"Refs: PROJ-1"}},
+		{"merged", "add parser\n\nRefs: PROJ-1\n", []string{"Signed-off-by: Ann <ann@example.com>", "Refs: PROJ-1"}, "add parser\n\nRefs: PROJ-1\nSigned-off-by: Ann <ann@example.com>\n", []string{"Signed-off-by: Ann <ann@example.com>"}},
+		{"token case", "add parser\n\nsigned-off-by: Ann <ann@example.com>\n", []string{"Signed-off-by: Ann <ann@example.com>"}, "add parser\n\nsigned-off-by: Ann <ann@example.com>\n", nil},
+		{"other value", "add parser\n\nRefs: PROJ-1\n", []string{"Refs: PROJ-2"}, "add parser\n\nRefs: PROJ-1\nRefs: PROJ-2\n", []string{"Refs: PROJ-2"}},
+		{"twice", "add parser\n", []string{"Refs: PROJ-1", "Refs: PROJ-1"}, "add parser\n\nRefs: PROJ-1\n", []string{"Refs: PROJ-1"}},
+		{"body is not a block", "add parser\n\nNote: keep it short.\nThe rest.\n", []string{"Refs: PROJ-1"}, "add parser\n\nNote: keep it short.\nThe rest.\n\nRefs: PROJ-1\n", []string{"Refs: PROJ-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, added := mergeTrailers(tt.msg, tt.trailers)
+			if got != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(added, tt.added) {
+				t.Errorf("added = %q, want %q", added, tt.added)
+			}
+		})
+	}
+}
+
+func Test_commitFlow_commitTrailers(t *testing.T) {
+	roster := []string{"Ann Lee <ann@example.com>", "Bob Ray <bob@example.com>", "Bea Ray <bea@example.com>"}
+	tests := []struct {
+		name    string
+		cfg     trailersConfig
+		names   []string
+		answers string
+		want    []string
+		wantErr string
+	}{
+		{"none", trailersConfig{}, nil, "", nil, ""},
+		{"signoff and refs", trailersConfig{Signoff: true, Refs: true}, nil, "", []string{"Signed-off-by: Agent <agent@example.com>", "Refs: PROJ-1234"}, ""},
+		{"roster", trailersConfig{Roster: roster}, []string{"ann", "bob@"}, "", []string{"Co-authored-by: Ann Lee <ann@example.com>", "Co-authored-by: Bob Ray <bob@example.com>"}, ""},
+		{"outsider", trailersConfig{Roster: roster}, []string{"Cy <cy@example.com>"}, "", []string{"Co-authored-by: Cy <cy@example.com>"}, ""},
+		{"ambiguous", trailersConfig{Roster: roster}, []string{"ray"}, "", nil, `co-author "ray" is ambiguous: Bob Ray <bob@example.com>, Bea Ray <bea@example.com>`},
+		{"unknown", trailersConfig{Roster: roster}, []string{"cy"}, "", nil, `co-author "cy" is not in trailers.roster`},
+		{"picked", trailersConfig{Roster: roster}, []string{pickCoAuthors}, "4\n3, 1 3\n", []string{"Co-authored-by: Bea Ray <bea@example.com>", "Co-authored-by: Ann Lee <ann@example.com>"}, ""},
+		{"none picked", trailersConfig{Roster: roster}, []string{pickCoAuthors}, "\n", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			repo.config = map[string]string{"user.name": "Agent", "user.email": "agent@example.com"}
+			flow := commitFlow{
+				repo:        repo,
+				in:          bufio.NewReader(strings.NewReader(tt.answers)),
+				out:         io.Discard,
+				context:     promptData{Ticket: "PROJ-1234"},
+				interactive: true,
+			}
+			got, err := flow.commitTrailers(tt.cfg, tt.names)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("trailers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_commitTrailers_noIdentity(t *testing.T) {
+	_, err := commitTrailers(newFakeRepo(), trailersConfig{Signoff: true}, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "user.name and user.email") {
+		t.Errorf("error = %v", err)
+	}
+}
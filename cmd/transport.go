@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// genDeps are what the generators reach outside of yag, replaced in tests
+// to run the commit commands offline.
+type genDeps struct {
+	client    *http.Client // http.DefaultClient when nil
+	token     tokenSource  // vertex ai access token, gcloud when nil
+	vertexURL string       // vertex ai base url, the regional endpoint when empty
+}
+
+func (d genDeps) httpClient() *http.Client {
+	if d.client == nil {
+		return http.DefaultClient
+	}
+	return d.client
+}
+
+// withConfig adds the timeouts and retries of the configuration to the
+// client, and fills the dependencies left to the configuration.
+func (d genDeps) withConfig(cfg config) genDeps {
+	d.client = &http.Client{Transport: newRetryTransport(cfg.HTTP, d.httpClient().Transport)}
+	if d.token == nil {
+		d.token = newTokenSource(cfg.Vertex, d.httpClient())
+	}
+	return d
+}
+
+func (d genDeps) tokenSource() tokenSource {
+	if d.token == nil {
+		return gcloudToken{}
+	}
+	return d.token
+}
+
+// vertexBaseURL is the endpoint of location.
+func (d genDeps) vertexBaseURL(location string) string {
+	if d.vertexURL != "" {
+		return strings.TrimSuffix(d.vertexURL, "/")
+	}
+	return fmt.Sprintf("https://%s-aiplatform.googleapis.com", location)
+}
+
+// tokenSource gives the bearer token of a request.
+type tokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
@@ -2,11 +2,20 @@ package main
 
 import (
 	"context"
+	"os"
+	"os/signal"
 
 	"github.com/lafourgale/fx/yag/cmd"
 	"github.com/spf13/cobra"
 )
 
 func main() {
-	cobra.CheckErr(cmd.NewCLI().ExecuteContext(context.Background()))
+	// Ctrl-C cancels the command context, aborting model requests.
+	// A second Ctrl-C kills yag as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	cobra.CheckErr(cmd.NewCLI().ExecuteContext(ctx))
 }